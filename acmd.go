@@ -1,20 +1,40 @@
 package acmd
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
-	"text/tabwriter"
+	"time"
+	"unicode"
 )
 
 // changed only in tests.
-var doExit = os.Exit
+var (
+	doExit        = os.Exit
+	geteuid       = os.Geteuid
+	userCacheDir  = os.UserCacheDir
+	userConfigDir = os.UserConfigDir
+	getwd         = os.Getwd
+	goos          = runtime.GOOS
+	goarch        = runtime.GOARCH
+	terminalWidth = termWidth
+	sudoBin       = "sudo"
+)
 
 // Runner of the sub-commands.
 type Runner struct {
@@ -22,8 +42,35 @@ type Runner struct {
 	cmds    []Command
 	errInit error
 
-	ctx  context.Context
-	args []string
+	ctx     context.Context
+	args    []string
+	rawArgs []string
+
+	noPager     bool
+	needsSelect bool
+	resume      bool
+	offline     bool
+	noInput     bool
+	bench       int
+	seed        int64
+	hasSeed     bool
+
+	// output TTY state before Output and ErrOutput are wrapped (ex: by LogFile).
+	outputTTY    bool
+	errOutputTTY bool
+
+	// providedBy is a provider index (from 1) by the top-level command name.
+	providedBy map[string]int
+
+	exitMu  sync.Mutex
+	eventMu sync.Mutex
+
+	// outputMu guards Output and ErrOutput swaps and messages printed by the Runner itself.
+	outputMu sync.Mutex
+	logFile  *logWriter
+
+	finalizeMu sync.Mutex
+	finalizers []func(ctx context.Context) error
 }
 
 // Command specifies a sub-command for a program's command-line interface.
@@ -37,6 +84,10 @@ type Command struct {
 	// Description of the command.
 	Description string
 
+	// DescriptionFile is a path in Config.DocsFS to a long description (ex: with examples)
+	// shown instead of Description by `help <command>`. It is read only when the help is shown.
+	DescriptionFile string
+
 	// ExecFunc represents the command function.
 	// Use Exec if you have struct implementing this function.
 	ExecFunc func(ctx context.Context, args []string) error
@@ -48,19 +99,137 @@ type Command struct {
 	// Subcommands of the command.
 	Subcommands []Command
 
+	// SubcommandsFunc returns subcommands when they are needed for the first time:
+	// to run one of them or to show the full help. Useful when the list is expensive to compute.
+	// Returned commands are validated and kept in Subcommands. Cannot be used with Subcommands.
+	SubcommandsFunc func() []Command
+
 	// IsHidden reports whether command should not be show in help. Default false.
+	// Subcommands of the hidden command are hidden too.
 	IsHidden bool
 
+	// Platforms where the command is supported in a form of "os" or "os/arch",
+	// ex: "linux" or "darwin/arm64". Empty means all the platforms.
+	// Unsupported commands are hidden from help and cannot be run.
+	Platforms []string
+
+	// Experimental commands are shown and can be run only when the Config.ExperimentalEnv
+	// environment variable is set to true (ex: MYAPP_EXPERIMENTAL=1). Default false.
+	Experimental bool
+
+	// EnabledIf is an optional gate for the command, ex: a feature flag check.
+	// If it returns false, the command with its subcommands is not shown and cannot be run.
+	EnabledIf func() bool
+
+	// RequiresRoot reports whether command must be run as root (ex: via sudo).
+	// Checked before the command execution. Default false.
+	RequiresRoot bool
+
+	// RequiresCapability is an optional check done before the command execution.
+	// Returned error is returned from Runner.Run and the command is not executed.
+	RequiresCapability func(ctx context.Context) error
+
+	// NeedsProject reports whether command must be run inside a project.
+	// Project root is found by Config.ProjectMarkers, see FindProjectRoot. Default false.
+	NeedsProject bool
+
+	// ArgChoices are allowed values for the positional args by index, ex: `{{"json", "yaml"}}`.
+	// Empty or missing choices allow any value. Args are validated before the execution.
+	ArgChoices [][]string
+
+	// KVArgs reports whether all the positional args must be `key=value` pairs,
+	// ex: `myapp set replicas=3 image=foo`. Args are validated before the execution, see ParseKV.
+	KVArgs bool
+
+	// Deprecated is a message for the deprecated command, ex: `use "deploy" instead`.
+	// A warning with it is printed when the command is run. See Warn.
+	Deprecated string
+
+	// PIDFile is a path of the pid file held while the command runs, see PIDFile func.
+	// The command is not run if the file is held by another running process.
+	PIDFile string
+
+	// RemovedInVersion of the app (Config.Version) since which the command cannot be run,
+	// ex: `v2.0.0`. Before that a deprecation warning is printed, after it an error is returned.
+	RemovedInVersion string
+
 	// FlagSet is an optional field where you can provide command's flags.
 	// Is used for autocomplete. Works best with https://github.com/cristalhq/flagx
 	FlagSet FlagsGetter
 }
 
+// HelpTopic is a help page which is not a command, ex: `myapp help auth`.
+type HelpTopic struct {
+	// Name of the topic, ex: `auth`.
+	Name string
+
+	// Title is a short description shown in the help.
+	Title string
+
+	// Content of the topic shown by `help <name>`.
+	Content string
+
+	// ContentFile is a path in Config.DocsFS to the content, used when Content is empty.
+	ContentFile string
+}
+
 // FlagsGetter returns flags for the command. See examples.
 type FlagsGetter interface {
 	Flags() *flag.FlagSet
 }
 
+// isSupported reports whether command can be run on the current platform.
+func (cmd *Command) isSupported() bool {
+	if len(cmd.Platforms) == 0 {
+		return true
+	}
+	for _, p := range cmd.Platforms {
+		if p == goos || p == goos+"/"+goarch {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEnabled returns an error if the command is experimental or its gate doesn't pass.
+func (cmd *Command) checkEnabled(cfg *Config) error {
+	if cmd.Experimental {
+		env := experimentalEnv(cfg)
+		if ok, _ := strconv.ParseBool(os.Getenv(env)); !ok {
+			return fmt.Errorf("is experimental, enable with %s=1", env)
+		}
+	}
+	if cmd.EnabledIf != nil && !cmd.EnabledIf() {
+		return errors.New("is not enabled")
+	}
+	return nil
+}
+
+// checkAvailable returns an error if the command at path cannot be run, see isSupported and checkEnabled.
+func (cmd *Command) checkAvailable(cfg *Config, path []string) error {
+	if !cmd.isSupported() {
+		return fmt.Errorf("command %q is not supported on %s/%s", strings.Join(path, " "), goos, goarch)
+	}
+	if err := cmd.checkEnabled(cfg); err != nil {
+		return fmt.Errorf("command %q %w", strings.Join(path, " "), err)
+	}
+	return nil
+}
+
+// experimentalEnv returns Config.ExperimentalEnv or the default one, ex: MYAPP_EXPERIMENTAL.
+func experimentalEnv(cfg *Config) string {
+	if cfg.ExperimentalEnv != "" {
+		return cfg.ExperimentalEnv
+	}
+	name := strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, filepath.Base(cfg.AppName))
+	return name + "_EXPERIMENTAL"
+}
+
 // simple way to get exec function.
 func (cmd *Command) getExec() func(ctx context.Context, args []string) error {
 	switch {
@@ -80,7 +249,7 @@ type Exec interface {
 
 // Config for the runner.
 type Config struct {
-	// AppName is an optional name for the app, if empty os.Args[0] will be used.
+	// AppName is an optional name for the app, if empty base name of os.Args[0] will be used.
 	AppName string
 
 	// AppDescription is an optional description. default is empty.
@@ -90,15 +259,66 @@ type Config struct {
 	PostDescription string
 
 	// Version of the application.
+	// If empty, version of the main module from the build info will be used (if any).
 	Version string
 
+	// HelpDescription of the help command, ex: for localization. Default is "shows help message".
+	HelpDescription string
+
+	// VersionDescription of the version command. Default is "shows version of the application".
+	VersionDescription string
+
+	// HelpCommand overrides the built-in help command, ex: to add `-h` alias.
+	// Description, Alias, FlagSet and IsHidden are taken from it, the name and the behavior are kept.
+	// The alias is reserved as the name is. Default is nil.
+	HelpCommand *Command
+
+	// VersionCommand overrides the built-in version command, see HelpCommand.
+	VersionCommand *Command
+
+	// BuiltinAliases adds `h` alias for help and `v` alias for version commands,
+	// unless other aliases are set in HelpCommand and VersionCommand.
+	// The aliases are reserved, so user commands cannot use them. Default is false.
+	BuiltinAliases bool
+
 	// Output is a destination where result will be printed.
 	// Exported for testing purpose only, if nil os.Stdout is used.
 	Output io.Writer
 
+	// ErrOutput is a destination where errors will be printed.
+	// Exported for testing purpose only, if nil os.Stderr is used.
+	ErrOutput io.Writer
+
+	// Input is a source of the user input for interactive features.
+	// Exported for testing purpose only, if nil os.Stdin is used.
+	Input io.Reader
+
 	// Context for commands, if nil context based on os.Interrupt and syscall.SIGTERM will be used.
 	Context context.Context
 
+	// CleanupTimeout is how long the command can clean up after the context is cancelled.
+	// See CleanupContext. With the default signal-based Context the app exits
+	// after this timeout even if the command hasn't returned. Default is 5 seconds.
+	CleanupTimeout time.Duration
+
+	// StallTimeout is how long the command can run without writing to Output or ErrOutput
+	// and without Progress events. After that "still working..." is printed to ErrOutput.
+	// Zero disables the watchdog. Default is zero.
+	StallTimeout time.Duration
+
+	// StallAbort makes a stalled command (see StallTimeout) abort the app:
+	// stacks of all goroutines are printed to ErrOutput to debug the hang and the app exits with 1.
+	StallAbort bool
+
+	// SystemdNotify enables sd_notify integration for commands started by systemd (NOTIFY_SOCKET env is set):
+	// WATCHDOG=1 is sent while the command runs if the service has WatchdogSec,
+	// STOPPING=1 is sent after the command returns. See NotifyReady and NotifyStatus.
+	SystemdNotify bool
+
+	// CheckpointTTL is how long checkpoints can be restored, see Checkpoint and Restore.
+	// Default is 7 days.
+	CheckpointTTL time.Duration
+
 	// Args passed to the executable, if nil os.Args[1:] will be used.
 	Args []string
 
@@ -108,9 +328,184 @@ type Config struct {
 	// VerboseHelp if "./app help -v" is passed, default is false.
 	VerboseHelp bool
 
+	// InteractiveSelect to let the user pick a command from a list
+	// when no command is passed and Input is a terminal. Default is false.
+	InteractiveSelect bool
+
+	// Suggest commands for the unknown input, if nil Levenshtein distance is used.
+	// Return nil to not suggest anything.
+	Suggest func(input string, cmds []Command) []string
+
+	// History of the executed commands is recorded to the user cache dir when true.
+	// Also adds `history` command to list them and `history rerun N` to run again.
+	// Args are stored in plain text (the file is readable by the user only),
+	// use HistoryRedact if they can contain secrets like tokens or passwords.
+	History bool
+
+	// HistoryRedact returns args to record in History, ex: with secret values replaced by "***".
+	// Return nil to not record the run. Redacted args are used by `history rerun N` too.
+	HistoryRedact func(args []string) []string
+
+	// Completion adds `completion <shell>` command printing a completion script, see Runner.CompletionScript.
+	// `completion init <shell>` prints a snippet for the shell rc file, ex: `source <(app completion init zsh)`.
+	Completion bool
+
+	// Which adds `which <command...>` command showing what the command path resolves to:
+	// a built-in command, a command, an alias or a command from Providers.
+	Which bool
+
+	// ProvenanceFile is a path of the provenance JSON written after the command succeeds:
+	// the build info of the binary, the args and SHA-256 of the files registered with Artifact.
+	// Default is empty, the provenance is not recorded.
+	ProvenanceFile string
+
+	// Licenses are third-party license notices shown by the `credits` command.
+	// The command is added only when it's set, use go:embed to keep the notices in a file.
+	// Modules the binary is built with are listed after the notices.
+	Licenses string
+
+	// HelpTopics are additional help pages shown by `help <topic>`.
+	HelpTopics []HelpTopic
+
+	// DocsFS with the docs files, see Command.DescriptionFile and HelpTopic.ContentFile.
+	// Usually it is embed.FS, so docs can be maintained as separate text files.
+	DocsFS fs.FS
+
+	// FirstRun is called once before the first ever command of the app.
+	// A marker file in the user config dir is created after it succeeds.
+	FirstRun func(ctx context.Context) error
+
+	// WarningsMode defines when warnings reported via Warn are printed. Default is WarningsDeferred.
+	WarningsMode WarningsMode
+
+	// EventOutput to write framework events (start, progress, warnings, end) as JSON lines.
+	// Regular output still goes to Output. If nil, events are not written,
+	// --output=jsonl (or --output jsonl) flag writes them to ErrOutput. See Event.
+	EventOutput io.Writer
+
+	// DryRun makes OpenFile and HTTPClient refuse mutations and print them instead,
+	// can be set with --dry-run flag if DryRunFlag is true. See IsDryRun.
+	DryRun bool
+
+	// BenchFlag enables hidden --bench N global flag which runs the command N times
+	// and reports wall time and allocations of the runs to ErrOutput.
+	// History and events are not recorded for such runs.
+	BenchFlag bool
+
+	// DryRunFlag enables --dry-run global flag which sets DryRun.
+	// Enable it only if the commands use OpenFile and HTTPClient for all the mutations.
+	DryRunFlag bool
+
+	// LogFile to write a copy of everything printed to Output and ErrOutput,
+	// can be set with --log-file=path flag if LogFileFlag is true.
+	// Each run is appended with a header containing time and the args.
+	// The file is closed when Run returns, Runner.Exit reopens it so the final error is logged too.
+	LogFile string
+
+	// LogFileFlag enables --log-file=path global flag which sets LogFile.
+	LogFileFlag bool
+
+	// LogMaxSize in bytes after which LogFile is rotated (renamed with .1 suffix).
+	// Zero means no rotation.
+	LogMaxSize int64
+
+	// SudoReexec runs the app again under sudo for commands with RequiresRoot
+	// when the app is not run as root and Input is a terminal. See ReexecWithSudo.
+	SudoReexec bool
+
+	// SudoEnv are environment variables preserved when the app is re-executed under sudo.
+	SudoEnv []string
+
+	// ProjectMarkers are files or dirs marking a project root for FindProjectRoot.
+	// Used for commands with NeedsProject. If nil, .git and go.mod are used.
+	ProjectMarkers []string
+
+	// ExitCodes maps errors (matched via errors.Is) to the exit codes used by Runner.Exit.
+	// ErrCode in the error chain takes precedence. If many errors match, the outermost one
+	// in the chain is used (ex: code of errConflict if it wraps errNotFound),
+	// the smallest code is used if they are matched on the same level.
+	ExitCodes map[error]int
+
+	// PermuteFlags moves flags before the positional args for commands with FlagSet,
+	// so `myapp copy src dst --force` is passed to the command as `--force src dst`.
+	// Flag values are recognised via FlagSet. Default is false.
+	PermuteFlags bool
+
+	// ShortFlagClusters expands clusters of single-letter flags for commands with FlagSet,
+	// so `-abc` is passed to the command as `-a -b -c` and `-n5` as `-n=5`. Default is false.
+	ShortFlagClusters bool
+
+	// UsageErrorsToStderr writes usage errors (ex: unknown command) to ErrOutput instead of Output
+	// and makes Runner.Exit use UsageErrorCode for them. Help requested by the user still goes to Output.
+	UsageErrorsToStderr bool
+
+	// UsageErrorCode is an exit code for usage errors when UsageErrorsToStderr is set. Default is 2.
+	UsageErrorCode int
+
+	// ExperimentalEnv is an environment variable to enable experimental commands.
+	// If empty, the upper-cased AppName with _EXPERIMENTAL suffix is used, ex: MYAPP_EXPERIMENTAL.
+	ExperimentalEnv string
+
+	// MultiCall runs the command directly when the executable is named after it (busybox-style),
+	// ex: `status` symlink to `myapp` binary runs `myapp status`. All the args are passed to the command,
+	// global flags (like --no-pager) are not parsed in this case. Default is false.
+	MultiCall bool
+
+	// PreprocessArgs rewrites the args (without the program name) before they are handled,
+	// ex: to translate legacy flags or map old command names. Global flags are parsed after it.
+	PreprocessArgs func(args []string) []string
+
+	// Providers of additional commands, ex: defined by a server. Called once on the Runner creation,
+	// their commands are validated with the others. Caching is up to the provider,
+	// with --offline flag only the cached commands should be used, see IsOffline.
+	Providers []CommandProvider
+
+	// HelpOnError prints the usage of the root or the parent command
+	// instead of the one-line hint when the command is not found. Default is false.
+	HelpOnError bool
+
+	// Wide disables truncation of the descriptions in help to the terminal width,
+	// can be set with --wide flag. Default is false.
+	Wide bool
+
+	// Plain disables colors and symbols in status lines, see Success, Failure and Info.
+	// Colors are also disabled when NO_COLOR env is set or the output is not a terminal.
+	Plain bool
+
+	// HelpMaxDepth limits how deep subcommands are shown in help.
+	// Deeper commands are collapsed into a single row. Zero means no limit.
+	HelpMaxDepth int
+
+	// UsePager to show help via $PAGER (less by default) when Output is a terminal.
+	// Can be disabled per run with the --no-pager flag before the command. Default is false.
+	UsePager bool
+
+	// BannedCommandNames are names that cannot be used as a command name or alias.
+	// Ex: to forbid "rm" or "delete" in the whole application.
+	BannedCommandNames []string
+
+	// NamePattern for the command names and aliases, the whole name must match it
+	// (ex: `[a-z]+` is treated as `^[a-z]+$`, so "rm -rf" doesn't match).
+	// If nil, only letters, digits, -, _, : and . are allowed.
+	NamePattern *regexp.Regexp
+
+	// AllowUnicodeNames allows non-ASCII letters and digits in the command names.
+	// Is ignored when NamePattern is set. Default is false.
+	AllowUnicodeNames bool
+
 	_ struct{} // enforce explicit field names.
 }
 
+// hasArg reports whether arg is presented in args.
+func hasArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
 // HasHelpFlag reports whether help flag is presented in args.
 func HasHelpFlag(flags []string) bool {
 	for _, f := range flags {
@@ -139,125 +534,469 @@ func RunnerOf(cmds []Command, cfg Config) *Runner {
 // Exit the application depending on the error.
 // If err is nil, so successful/no error exit is done: os.Exit(0)
 // If err is of type ErrCode: code from the error is returned: os.Exit(code)
+// If err matches a key of Config.ExitCodes (via errors.Is): os.Exit(value)
 // Otherwise: os.Exit(1).
+// Error (and its hint, see WithHint) is printed to Config.ErrOutput. Safe to call concurrently.
 func (r *Runner) Exit(err error) {
+	r.exitMu.Lock()
+	defer r.exitMu.Unlock()
+
+	closeLog := r.reopenLogFile()
 	if err == nil {
+		r.finalize()
+		closeLog()
 		doExit(0)
 		return
 	}
+
+	r.printErr("%s: %s\n", r.cfg.AppName, err.Error())
+	if hint := ErrorHint(err); hint != "" {
+		r.printErr("hint: %s\n", hint)
+	}
+	r.finalize()
+	closeLog()
+	doExit(r.exitCode(err))
+}
+
+// errOutput is ErrOutput or os.Stderr if the Runner is not initialized.
+func (r *Runner) errOutput() io.Writer {
+	if r.cfg.ErrOutput == nil {
+		return os.Stderr
+	}
+	return r.cfg.ErrOutput
+}
+
+// printErr prints a message of the Runner itself to ErrOutput.
+// Safe to call concurrently with other messages and with Output and ErrOutput swaps.
+func (r *Runner) printErr(format string, args ...interface{}) {
+	r.outputMu.Lock()
+	defer r.outputMu.Unlock()
+	fmt.Fprintf(r.errOutput(), format, args...)
+}
+
+// exitCode for the error, see Exit.
+func (r *Runner) exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var usageErr usageError
+	if errors.As(err, &usageErr) {
+		return usageErr.code
+	}
+
 	errCode := ErrCode(1)
-	errors.As(err, &errCode)
+	if !errors.As(err, &errCode) {
+		if code, ok := mappedExitCode(err, r.cfg.ExitCodes); ok {
+			return code
+		}
+	}
+	return int(errCode)
+}
 
-	fmt.Fprintf(r.cfg.Output, "%s: %s\n", r.cfg.AppName, err.Error())
-	doExit(int(errCode))
+// mappedExitCode returns the code of the outermost error in the chain matching a key of codes.
+func mappedExitCode(err error, codes map[error]int) (int, bool) {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		next := errors.Unwrap(e)
+		code, found := 0, false
+		for target, c := range codes {
+			// target is matched on this level if it isn't matched deeper.
+			if errors.Is(e, target) && !errors.Is(next, target) && (!found || c < code) {
+				code, found = c, true
+			}
+		}
+		if found {
+			return code, true
+		}
+	}
+	return 0, false
 }
 
 func (r *Runner) init() error {
 	if r.cfg.Output == nil {
 		r.cfg.Output = os.Stdout
 	}
+	if r.cfg.ErrOutput == nil {
+		r.cfg.ErrOutput = os.Stderr
+	}
+	r.outputTTY = IsTerminal(r.cfg.Output)
+	r.errOutputTTY = IsTerminal(r.cfg.ErrOutput)
+	if r.cfg.Input == nil {
+		r.cfg.Input = os.Stdin
+	}
+	if r.cfg.UsageErrorCode == 0 {
+		r.cfg.UsageErrorCode = 2
+	}
+	if r.cfg.CheckpointTTL == 0 {
+		r.cfg.CheckpointTTL = 7 * 24 * time.Hour
+	}
+	if r.cfg.CleanupTimeout == 0 {
+		r.cfg.CleanupTimeout = 5 * time.Second
+	}
 
 	if r.cfg.Usage == nil {
 		r.cfg.Usage = defaultUsage(r)
 	}
 
+	if r.cfg.HelpDescription == "" {
+		r.cfg.HelpDescription = "shows help message"
+	}
+	if r.cfg.VersionDescription == "" {
+		r.cfg.VersionDescription = "shows version of the application"
+	}
+	if r.cfg.Version == "" {
+		r.cfg.Version = buildVersion()
+	}
+
 	r.args = r.cfg.Args
 	if r.args == nil {
 		r.args = os.Args
 	} else if len(r.args) == 0 {
 		return ErrNoArgs
 	}
+	r.rawArgs = append([]string(nil), r.args...)
 
 	if r.cfg.AppName == "" {
-		r.cfg.AppName = r.args[0]
+		r.cfg.AppName = filepath.Base(r.args[0])
 	}
 
-	r.args = r.args[1:]
+	args := r.args[1:]
+	if r.cfg.PreprocessArgs != nil {
+		args = r.cfg.PreprocessArgs(append([]string(nil), args...))
+	}
+	if name, ok := r.multiCallName(); ok {
+		// all the args belong to the command, global flags are not parsed.
+		r.args = append([]string{name}, args...)
+	} else {
+		flags, args := r.parseGlobalFlags(args)
+		if err := r.applyGlobalFlags(flags); err != nil {
+			return err
+		}
+		r.args = args
+	}
 	if len(r.args) == 0 {
-		return ErrNoArgs
+		if !r.cfg.InteractiveSelect || r.noInput || !IsTerminal(r.cfg.Input) {
+			return ErrNoArgs
+		}
+		r.needsSelect = true
 	}
 
 	r.ctx = r.cfg.Context
 	if r.ctx == nil {
 		// ok to ignore cancel func because os.Interrupt and syscall.SIGTERM is already almost os.Exit
 		r.ctx, _ = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		r.ctx = context.WithValue(r.ctx, signalContextKey{}, true)
+	}
+	r.ctx = r.withFlags(r.ctx)
+
+	if err := r.loadProviders(); err != nil {
+		return err
 	}
 
-	fakeRootCmd := Command{
-		Name:        "root",
-		Subcommands: r.cmds,
+	if r.cfg.BuiltinAliases {
+		r.cfg.HelpCommand = withDefaultAlias(r.cfg.HelpCommand, "h")
+		r.cfg.VersionCommand = withDefaultAlias(r.cfg.VersionCommand, "v")
+	}
+	if err := validateBuiltinOverride(&r.cfg, "help", r.cfg.HelpCommand); err != nil {
+		return err
 	}
-	if err := validateCommand(fakeRootCmd); err != nil {
+	if err := validateBuiltinOverride(&r.cfg, "version", r.cfg.VersionCommand); err != nil {
+		return err
+	}
+	if err := validateSubcommands(&r.cfg, nil, r.cmds); err != nil {
 		return err
 	}
 
 	r.cmds = append(r.cmds,
-		Command{
+		builtinOverride(Command{
 			Name:        "help",
-			Description: "shows help message",
-			ExecFunc: func(ctx context.Context, args []string) error {
-				r.cfg.Usage(r.cfg, r.cmds)
+			Description: r.cfg.HelpDescription,
+			ExecFunc: builtinFunc(func(ctx context.Context, args []string) error {
+				if len(args) != 0 && !strings.HasPrefix(args[0], "-") {
+					return r.printHelpFor(args)
+				}
+				if hasArg(args, "--all") {
+					opts, err := snapshotOptionsFrom(args)
+					if err != nil {
+						return err
+					}
+					if hasArg(args, "--plain") {
+						fmt.Fprint(r.cfg.Output, r.Snapshot(opts))
+					} else {
+						r.printPaged(r.cfg.Output, r.commandTree(false, opts))
+					}
+					return nil
+				}
+				if hasArg(args, "--index") {
+					r.printIndex()
+					return nil
+				}
+				r.printUsage(r.cfg, r.cmds)
 				return nil
-			},
-		},
-		Command{
+			}),
+		}, r.cfg.HelpCommand),
+		builtinOverride(Command{
 			Name:        "version",
-			Description: "shows version of the application",
-			ExecFunc: func(ctx context.Context, args []string) error {
+			Description: r.cfg.VersionDescription,
+			ExecFunc: builtinFunc(func(ctx context.Context, args []string) error {
 				fmt.Fprintf(r.cfg.Output, "%s version: %s\n\n", r.cfg.AppName, r.cfg.Version)
 				return nil
-			},
+			}),
+		}, r.cfg.VersionCommand),
+		Command{
+			Name:        "__resolve",
+			Description: "prints how the args are resolved to a command",
+			IsHidden:    true,
+			ExecFunc: builtinFunc(func(ctx context.Context, args []string) error {
+				return r.printResolve(args)
+			}),
 		},
 	)
 
+	if r.cfg.History {
+		r.cmds = append(r.cmds, r.historyCmd())
+	}
+	if r.cfg.Licenses != "" {
+		r.cmds = append(r.cmds, r.creditsCmd())
+	}
+	if r.cfg.Completion {
+		r.cmds = append(r.cmds, r.completionCmd())
+	}
+	if r.cfg.Which {
+		r.cmds = append(r.cmds, r.whichCmd())
+	}
+
 	sort.Slice(r.cmds, func(i, j int) bool {
 		return r.cmds[i].Name < r.cmds[j].Name
 	})
 	return nil
 }
 
-func validateCommand(cmd Command) error {
+// validateBuiltinOverride of the built-in command with the given name, see Config.HelpCommand.
+func validateBuiltinOverride(cfg *Config, name string, override *Command) error {
+	switch {
+	case override == nil:
+		return nil
+	case override.Name != "" && override.Name != name:
+		return fmt.Errorf("%s command override cannot change the name to %q", name, override.Name)
+	case override.getExec() != nil || len(override.Subcommands) != 0 || override.SubcommandsFunc != nil:
+		return fmt.Errorf("%s command override cannot have exec function or subcommands", name)
+	case override.Alias != "" && !isNameValid(cfg, override.Alias):
+		return ErrInvalidName{Name: override.Alias, Path: name, IsAlias: true, Rule: nameRule(cfg)}
+	}
+	return nil
+}
+
+// withDefaultAlias returns a copy of the built-in command override with the alias if it has none.
+func withDefaultAlias(override *Command, alias string) *Command {
+	var cmd Command
+	if override != nil {
+		cmd = *override
+	}
+	if cmd.Alias == "" {
+		cmd.Alias = alias
+	}
+	return &cmd
+}
+
+// builtinOverride applies the override from the config to the built-in command.
+func builtinOverride(cmd Command, override *Command) Command {
+	if override == nil {
+		return cmd
+	}
+	if override.Description != "" {
+		cmd.Description = override.Description
+	}
+	cmd.Alias = override.Alias
+	cmd.FlagSet = override.FlagSet
+	cmd.IsHidden = override.IsHidden
+	return cmd
+}
+
+// multiCallName returns the command name if the executable is named after it, see Config.MultiCall.
+func (r *Runner) multiCallName() (string, bool) {
+	if !r.cfg.MultiCall {
+		return "", false
+	}
+	exe := r.cfg.Args
+	if exe == nil {
+		exe = os.Args
+	}
+	name := strings.TrimSuffix(filepath.Base(exe[0]), ".exe")
+
+	for _, cmd := range r.cmds {
+		if name == cmd.Name || (cmd.Alias != "" && name == cmd.Alias) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// builtinFunc returns fn which is not run if ctx is already done.
+func builtinFunc(fn func(ctx context.Context, args []string) error) func(ctx context.Context, args []string) error {
+	return func(ctx context.Context, args []string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(ctx, args)
+	}
+}
+
+// buildVersion returns version of the main module, ex: for `go install`-ed binaries.
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "(devel)" {
+		return ""
+	}
+	return info.Main.Version
+}
+
+// globalFlags are values of the flags handled by the runner itself, see parseGlobalFlags.
+type globalFlags struct {
+	noPager bool
+	dryRun  bool
+	resume  bool
+	offline bool
+	noInput bool
+	wide    bool
+	jsonl   bool
+	logFile string
+	bench   string
+	seed    string
+}
+
+// parseGlobalFlags returns flags that are handled by the runner itself and the rest of args.
+// Only flags before the command name are considered. The Runner is not changed, see applyGlobalFlags.
+func (r *Runner) parseGlobalFlags(args []string) (globalFlags, []string) {
+	var f globalFlags
+	for len(args) > 0 {
+		arg := args[0]
+		switch {
+		case arg == "--no-pager":
+			f.noPager = true
+		case arg == "--dry-run" && r.cfg.DryRunFlag:
+			f.dryRun = true
+		case arg == "--resume":
+			f.resume = true
+		case arg == "--offline":
+			f.offline = true
+		case arg == "--no-input":
+			f.noInput = true
+		case arg == "--wide":
+			f.wide = true
+		case arg == "--output=jsonl" || (arg == "--output" && len(args) > 1 && args[1] == "jsonl"):
+			if arg == "--output" {
+				args = args[1:]
+			}
+			f.jsonl = true
+		case strings.HasPrefix(arg, "--log-file=") && r.cfg.LogFileFlag:
+			f.logFile = strings.TrimPrefix(arg, "--log-file=")
+		case strings.HasPrefix(arg, "--bench=") && r.cfg.BenchFlag:
+			f.bench = strings.TrimPrefix(arg, "--bench=")
+		case arg == "--bench" && len(args) > 1 && r.cfg.BenchFlag:
+			f.bench = args[1]
+			args = args[1:]
+		case strings.HasPrefix(arg, "--seed="):
+			f.seed = strings.TrimPrefix(arg, "--seed=")
+		case arg == "--seed" && len(args) > 1:
+			f.seed = args[1]
+			args = args[1:]
+		default:
+			return f, args
+		}
+		args = args[1:]
+	}
+	return f, args
+}
+
+// applyGlobalFlags parsed by parseGlobalFlags to the Runner.
+func (r *Runner) applyGlobalFlags(f globalFlags) error {
+	r.noPager = r.noPager || f.noPager
+	r.resume = r.resume || f.resume
+	r.offline = r.offline || f.offline
+	r.noInput = r.noInput || f.noInput
+	r.cfg.DryRun = r.cfg.DryRun || f.dryRun
+	r.cfg.Wide = r.cfg.Wide || f.wide
+	if f.jsonl && r.cfg.EventOutput == nil {
+		r.cfg.EventOutput = r.cfg.ErrOutput
+	}
+	if f.logFile != "" {
+		r.cfg.LogFile = f.logFile
+	}
+	if f.bench != "" {
+		n, err := strconv.Atoi(f.bench)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid --bench value %q", f.bench)
+		}
+		r.bench = n
+	}
+	if f.seed != "" {
+		seed, err := strconv.ParseInt(f.seed, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid --seed value %q", f.seed)
+		}
+		r.seed, r.hasSeed = seed, true
+	}
+	return nil
+}
+
+func validateCommand(cfg *Config, parent []string, cmd Command) error {
 	cmds := cmd.Subcommands
+	path := strings.Join(append(parent[:len(parent):len(parent)], cmd.Name), " ")
+	hasSubcommands := len(cmds) != 0 || cmd.SubcommandsFunc != nil
 
 	switch {
-	case cmd.getExec() == nil && len(cmds) == 0:
+	case cmd.getExec() == nil && !hasSubcommands:
 		return fmt.Errorf("command %q exec function cannot be nil OR must have subcommands", cmd.Name)
 
-	case cmd.getExec() != nil && len(cmds) != 0:
+	case cmd.getExec() != nil && hasSubcommands:
 		return fmt.Errorf("command %q exec function cannot be set AND have subcommands", cmd.Name)
 
-	case cmd.Name == "help" || cmd.Name == "version":
-		return fmt.Errorf("command %q is reserved", cmd.Name)
+	case len(cmds) != 0 && cmd.SubcommandsFunc != nil:
+		return fmt.Errorf("command %q cannot have both Subcommands and SubcommandsFunc", cmd.Name)
+
+	case cmd.DescriptionFile != "" && cfg.DocsFS == nil:
+		return fmt.Errorf("command %q has DescriptionFile but Config.DocsFS is nil", cmd.Name)
+
+	case isReserved(cfg, cmd.Name):
+		return ErrReservedName{Name: cmd.Name, Path: path}
+
+	case isReserved(cfg, cmd.Alias):
+		return ErrReservedName{Name: cmd.Alias, Path: path, IsAlias: true}
+
+	case isNameBanned(cfg, cmd.Name):
+		return ErrInvalidName{Name: cmd.Name, Path: path, Rule: "is banned"}
 
-	case cmd.Alias == "help" || cmd.Alias == "version":
-		return fmt.Errorf("command alias %q is reserved", cmd.Alias)
+	case cmd.Alias != "" && isNameBanned(cfg, cmd.Alias):
+		return ErrInvalidName{Name: cmd.Alias, Path: path, IsAlias: true, Rule: "is banned"}
 
-	case !isNameValid(cmd.Name):
-		return fmt.Errorf("command %q must contains only letters, digits, - and _", cmd.Name)
+	case !isNameValid(cfg, cmd.Name):
+		return ErrInvalidName{Name: cmd.Name, Path: path, Rule: nameRule(cfg)}
 
-	case cmd.Alias != "" && !isNameValid(cmd.Alias):
-		return fmt.Errorf("command alias %q must contains only letters, digits, - and _", cmd.Alias)
+	case cmd.Alias != "" && !isNameValid(cfg, cmd.Alias):
+		return ErrInvalidName{Name: cmd.Alias, Path: path, IsAlias: true, Rule: nameRule(cfg)}
 
 	case len(cmds) != 0:
-		if err := validateSubcommands(cmds); err != nil {
+		if err := validateSubcommands(cfg, append(parent[:len(parent):len(parent)], cmd.Name), cmds); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func validateSubcommands(cmds []Command) error {
+func validateSubcommands(cfg *Config, parent []string, cmds []Command) error {
 	sort.Slice(cmds, func(i, j int) bool {
 		return cmds[i].Name < cmds[j].Name
 	})
 
 	names := make(map[string]struct{})
 	for _, cmd := range cmds {
+		path := strings.Join(append(parent[:len(parent):len(parent)], cmd.Name), " ")
+
 		if _, ok := names[cmd.Name]; ok {
-			return fmt.Errorf("duplicate command %q", cmd.Name)
+			return ErrDuplicateCommand{Name: cmd.Name, Path: path}
 		}
 		if _, ok := names[cmd.Alias]; ok {
-			return fmt.Errorf("duplicate command alias %q", cmd.Alias)
+			return ErrDuplicateCommand{Name: cmd.Alias, Path: path, IsAlias: true}
 		}
 
 		names[cmd.Name] = struct{}{}
@@ -265,77 +1004,507 @@ func validateSubcommands(cmds []Command) error {
 			names[cmd.Alias] = struct{}{}
 		}
 
-		if err := validateCommand(cmd); err != nil {
+		if err := validateCommand(cfg, parent, cmd); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func isNameValid(s string) bool {
+// nameRule describes which names are valid, used in errors.
+func nameRule(cfg *Config) string {
+	if cfg.NamePattern != nil {
+		return fmt.Sprintf("must match %q", cfg.NamePattern.String())
+	}
+	return "must contains only letters, digits, -, _, : and ."
+}
+
+// cmdName is a name or an alias of the command with a full path to it.
+type cmdName struct {
+	path    string
+	isAlias bool
+}
+
+func (n cmdName) String() string {
+	if n.isAlias {
+		return fmt.Sprintf("%q (alias)", n.path)
+	}
+	return fmt.Sprintf("%q", n.path)
+}
+
+// AmbiguityReport lists names and aliases of subcommands that shadow an alias
+// (or a name for aliases) of commands on any upper level, with full paths, ex:
+// `"b" of "bar b" shadows "foo" (alias)`. Such trees are dispatched fine by the Runner,
+// but `app b` and `app bar b` are easy to confuse for the users.
+// Same names on different levels are fine, ex: `app add` and `app remote add`.
+// Subcommands from Command.SubcommandsFunc are checked only if already loaded.
+func (r *Runner) AmbiguityReport() []string {
+	var report []string
+	collectAmbiguity(r.cmds, "", nil, &report)
+	return report
+}
+
+// collectAmbiguity of cmds with the names of the commands on the upper levels.
+func collectAmbiguity(cmds []Command, prefix string, upper map[string][]cmdName, report *[]string) {
+	check := func(name string, n cmdName) {
+		for _, u := range upper[name] {
+			if n.isAlias || u.isAlias {
+				*report = append(*report, fmt.Sprintf("%q of %s shadows %s", name, n, u))
+			}
+		}
+	}
+
+	levelUpper := make(map[string][]cmdName, len(upper)+2*len(cmds))
+	for name, names := range upper {
+		levelUpper[name] = names
+	}
+	add := func(name string, n cmdName) {
+		// slices are shared with the upper levels, so they are copied on append.
+		names := levelUpper[name]
+		levelUpper[name] = append(names[:len(names):len(names)], n)
+	}
+
+	for _, cmd := range cmds {
+		path := strings.TrimSpace(prefix + " " + cmd.Name)
+		check(cmd.Name, cmdName{path: path})
+		add(cmd.Name, cmdName{path: path})
+		if cmd.Alias != "" {
+			check(cmd.Alias, cmdName{path: path, isAlias: true})
+			add(cmd.Alias, cmdName{path: path, isAlias: true})
+		}
+	}
+
+	for _, cmd := range cmds {
+		collectAmbiguity(cmd.Subcommands, strings.TrimSpace(prefix+" "+cmd.Name), levelUpper, report)
+	}
+}
+
+// isReserved reports whether s is a name of the builtin command.
+func isReserved(cfg *Config, s string) bool {
+	switch s {
+	case "help", "version", "__resolve":
+		return true
+	case "history":
+		return cfg.History
+	case "credits":
+		return cfg.Licenses != ""
+	case "completion":
+		return cfg.Completion
+	case "which":
+		return cfg.Which
+	case "":
+		return false
+	default:
+		return (cfg.HelpCommand != nil && s == cfg.HelpCommand.Alias) ||
+			(cfg.VersionCommand != nil && s == cfg.VersionCommand.Alias)
+	}
+}
+
+func isNameBanned(cfg *Config, s string) bool {
+	for _, banned := range cfg.BannedCommandNames {
+		if s == banned {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidName reports whether s is a valid command name or alias with the default rules:
+// not empty and contains only ASCII letters, digits, -, _, : and .
+// Use Runner.ValidateName to check a name against the Runner config.
+func IsValidName(s string) bool {
+	return isNameValid(&Config{}, s)
+}
+
+// ValidateName checks whether name can be used as a command name or alias with the Runner config:
+// it must not be reserved by a builtin command or banned and must follow Config.NamePattern
+// (or the default rules, see IsValidName). Returns ErrReservedName or ErrInvalidName.
+// Duplicates are not checked as they depend on the parent command.
+func (r *Runner) ValidateName(name string) error {
+	if r.errInit != nil {
+		return r.errInit
+	}
+	switch {
+	case isReserved(&r.cfg, name):
+		return ErrReservedName{Name: name}
+	case isNameBanned(&r.cfg, name):
+		return ErrInvalidName{Name: name, Rule: "is banned"}
+	case !isNameValid(&r.cfg, name):
+		return ErrInvalidName{Name: name, Rule: nameRule(&r.cfg)}
+	}
+	return nil
+}
+
+func isNameValid(cfg *Config, s string) bool {
 	if s == "" {
 		return false
 	}
+	if cfg.NamePattern != nil {
+		return matchFull(cfg.NamePattern, s)
+	}
 	for _, c := range s {
-		if !(('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') ||
-			('0' <= c && c <= '9') || c == '-' || c == '_' || c == ':' || c == '.') {
+		switch {
+		case ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9'):
+		case c == '-' || c == '_' || c == ':' || c == '.':
+		case cfg.AllowUnicodeNames && (unicode.IsLetter(c) || unicode.IsDigit(c)):
+		default:
 			return false
 		}
 	}
 	return true
 }
 
+// matchFull reports whether the whole s matches re, not only its part.
+func matchFull(re *regexp.Regexp, s string) bool {
+	full, err := regexp.Compile(`^(?:` + re.String() + `)$`)
+	return err == nil && full.MatchString(s)
+}
+
 // Run commands.
 func (r *Runner) Run() error {
+	return r.run(r.ctx)
+}
+
+// RunContext runs commands like Run but with ctx instead of Config.Context.
+// Useful when the Runner is created once and each run needs its own context, ex: in tests.
+func (r *Runner) RunContext(ctx context.Context) error {
+	return r.run(r.withFlags(ctx))
+}
+
+// withFlags adds values of the global flags to ctx.
+func (r *Runner) withFlags(ctx context.Context) context.Context {
+	if r.offline {
+		ctx = context.WithValue(ctx, offlineKey{}, true)
+	}
+	if r.noInput {
+		ctx = context.WithValue(ctx, noInputKey{}, true)
+	}
+	return ctx
+}
+
+func (r *Runner) run(ctx context.Context) error {
 	if r.errInit != nil {
 		return r.errInit
 	}
-	cmd, params, err := findCmd(r.cfg, r.cmds, r.args)
+	if r.needsSelect {
+		args, err := r.selectCommand()
+		if err != nil {
+			return err
+		}
+		r.args = args
+	}
+
+	if r.cfg.LogFile != "" {
+		if err := r.teeToLogFile(); err != nil {
+			return err
+		}
+		defer r.logFile.Close()
+	}
+
+	if err := r.runFirstRun(ctx); err != nil {
+		return err
+	}
+	if r.bench > 0 {
+		return r.runBench(ctx, r.args)
+	}
+	return r.runArgs(ctx, r.args)
+}
+
+// runArgs finds the command for args and runs it.
+func (r *Runner) runArgs(ctx context.Context, args []string) error {
+	path, cmd, params, err := findCmd(r.cfg, r.cmds, args)
+	if err != nil {
+		var notFound ErrUnknownCommand
+		if errors.As(err, &notFound) {
+			r.printNotFound(notFound)
+		}
+		return err
+	}
+	if cmd.getExec() == nil {
+		return r.printCommandHelp(path, cmd)
+	}
+	if cmd.RequiresRoot && geteuid() != 0 && r.cfg.SudoReexec && IsTerminal(r.cfg.Input) {
+		return r.reexecWithSudo(ctx)
+	}
+	if err := r.checkRequirements(ctx, path, cmd, params); err != nil {
+		return err
+	}
+
+	inv := &invocation{runner: r, path: path}
+	cleanupCtx, stopCleanup := r.cleanupContext(ctx, inv)
+	defer stopCleanup()
+	inv.cleanupCtx = cleanupCtx
+	ctx = withInvocation(ctx, inv)
+	defer inv.removeTempDir()
+	defer inv.removePIDFiles()
+	defer inv.unlockCheckpoints()
+
+	if cmd.PIDFile != "" {
+		if err := PIDFile(ctx, cmd.PIDFile); err != nil {
+			return err
+		}
+	}
+
+	warnDeprecated(ctx, path, cmd)
+
+	params = r.normalizeArgs(cmd, params)
+	if r.bench == 0 {
+		r.emitEvent(Event{Type: "start", Command: strings.Join(path, " "), Args: params})
+	}
+
+	stopWatchdog := r.startStallWatchdog(inv)
+	stopNotify := r.startSystemdNotify()
+	err = cmd.getExec()(ctx, params)
+	stopNotify()
+	stopWatchdog()
+	if err != nil || (ctx.Err() != nil && inv.hasUndos()) {
+		err = inv.undo(ctx, err)
+	}
+	if err == nil && r.cfg.ProvenanceFile != "" {
+		err = inv.writeProvenance(r.cfg.ProvenanceFile)
+	}
+	inv.printWarnings()
+	if err != nil {
+		inv.printSeed()
+	}
+	if err == nil {
+		inv.removeCheckpoints()
+	}
+
+	if r.bench > 0 {
+		return err
+	}
+
+	code := r.exitCode(err)
+	end := Event{Type: "end", Command: strings.Join(path, " "), Code: &code}
+	if err != nil {
+		end.Message = err.Error()
+	}
+	r.emitEvent(end)
+
+	if r.cfg.History && path[0] != "history" {
+		r.recordHistory(args, err)
+	}
+	return err
+}
+
+// checkRequirements of the command before the execution.
+func (r *Runner) checkRequirements(ctx context.Context, path []string, cmd *Command, params []string) error {
+	if err := r.checkRemoved(path, cmd); err != nil {
+		return err
+	}
+	if cmd.RequiresRoot && geteuid() != 0 {
+		return fmt.Errorf("command %q: %w", strings.Join(path, " "), ErrRequiresRoot)
+	}
+	if cmd.NeedsProject {
+		if _, err := FindProjectRoot(ctx, r.cfg.ProjectMarkers...); err != nil {
+			return fmt.Errorf("command %q: %w", strings.Join(path, " "), err)
+		}
+	}
+	if err := checkArgChoices(path, cmd, params); err != nil {
+		return err
+	}
+	if err := checkKVArgs(path, cmd, params); err != nil {
+		return err
+	}
+	if cmd.RequiresCapability != nil {
+		return cmd.RequiresCapability(ctx)
+	}
+	return nil
+}
+
+// checkArgChoices of the positional args, see Command.ArgChoices.
+func checkArgChoices(path []string, cmd *Command, params []string) error {
+	if len(cmd.ArgChoices) == 0 {
+		return nil
+	}
+
+	var fset *flag.FlagSet
+	if cmd.FlagSet != nil {
+		fset = cmd.FlagSet.Flags()
+	}
+
+	for i, arg := range positionalArgs(fset, params) {
+		if i >= len(cmd.ArgChoices) || len(cmd.ArgChoices[i]) == 0 {
+			continue
+		}
+		choices := cmd.ArgChoices[i]
+		if !hasArg(choices, arg) {
+			quoted := make([]string, len(choices))
+			for j, c := range choices {
+				quoted[j] = strconv.Quote(c)
+			}
+			return fmt.Errorf("command %q: invalid value %q for arg %d, expected one of %s",
+				strings.Join(path, " "), arg, i+1, strings.Join(quoted, ", "))
+		}
+	}
+	return nil
+}
+
+// checkKVArgs of the positional args, see Command.KVArgs.
+func checkKVArgs(path []string, cmd *Command, params []string) error {
+	if !cmd.KVArgs {
+		return nil
+	}
+
+	var fset *flag.FlagSet
+	if cmd.FlagSet != nil {
+		fset = cmd.FlagSet.Flags()
+	}
+
+	_, rest, err := ParseKV(positionalArgs(fset, params))
+	switch {
+	case err != nil:
+		return fmt.Errorf("command %q: %w", strings.Join(path, " "), err)
+	case len(rest) != 0:
+		return fmt.Errorf("command %q: arg %q is not a key=value pair", strings.Join(path, " "), rest[0])
+	}
+	return nil
+}
+
+// printResolve prints what would be run for the given args. Used for debugging.
+func (r *Runner) printResolve(args []string) error {
+	_, rest := r.parseGlobalFlags(args)
+	globals := args[:len(args)-len(rest)]
+
+	path, _, params, err := r.Resolve(rest)
 	if err != nil {
 		return err
 	}
-	return cmd(r.ctx, params)
+
+	fmt.Fprintf(r.cfg.Output, "global flags: %q\n", globals)
+	fmt.Fprintf(r.cfg.Output, "command: %q\n", strings.Join(path, " "))
+	fmt.Fprintf(r.cfg.Output, "params: %q\n", params)
+	return nil
+}
+
+// Resolve finds a command for the given args (without app name) but doesn't run it.
+// Returns the names of the commands from the root to the found one,
+// the command itself and the rest of the args that would be passed to it.
+// For a help flag after a parent command, ex: `test --help`, the parent command is returned.
+// Nothing is printed, ErrUnknownCommand is returned if there is no such command.
+func (r *Runner) Resolve(args []string) (path []string, cmd *Command, rest []string, err error) {
+	if r.errInit != nil {
+		return nil, nil, nil, r.errInit
+	}
+	if len(args) == 0 {
+		return nil, nil, nil, ErrNoArgs
+	}
+	return findCmd(r.cfg, r.cmds, args)
 }
 
-func findCmd(cfg Config, cmds []Command, args []string) (func(ctx context.Context, args []string) error, []string, error) {
+func findCmd(cfg Config, cmds []Command, args []string) ([]string, *Command, []string, error) {
+	var path []string
+	var parent *Command
 	for {
 		selected, params := args[0], args[1:]
 
 		var found bool
-		for _, c := range cmds {
-			if selected != c.Name && selected != c.Alias {
+		for i := range cmds {
+			c := &cmds[i]
+			if selected != c.Name && (c.Alias == "" || selected != c.Alias) {
 				continue
 			}
+			path = append(path, c.Name)
+
+			if err := c.checkAvailable(&cfg, path); err != nil {
+				return nil, nil, nil, err
+			}
 
 			// go deeper into subcommands
 			if c.getExec() == nil {
 				if len(params) == 0 {
-					return nil, nil, errors.New("no args for command provided")
+					return nil, nil, nil, errors.New("no args for command provided")
+				}
+				if err := c.loadSubcommands(&cfg, path); err != nil {
+					return nil, nil, nil, err
+				}
+				// parent itself is returned for its help, ex: `app test --help`
+				if HasHelpFlag(params[:1]) {
+					return path, c, params, nil
 				}
 				cmds, args = c.Subcommands, params
+				parent = c
 				found = true
 				break
 			}
-			return c.getExec(), params, nil
+			return path, c, params, nil
 		}
 
 		if !found {
-			return nil, nil, errNotFoundAndSuggest(cfg.Output, cfg.AppName, selected, cmds)
+			return nil, nil, nil, errNotFound(&cfg, path, parent, selected, cmds)
 		}
 	}
 }
 
-func errNotFoundAndSuggest(w io.Writer, appName, selected string, cmds []Command) error {
-	suggestion := suggestCommand(selected, cmds)
-	if suggestion != "" {
-		fmt.Fprintf(w, "%q unknown command, did you mean %q?\n", selected, suggestion)
-	} else {
-		fmt.Fprintf(w, "%q unknown command\n", selected)
+// errNotFound for the unknown command selected under the parent path,
+// suggestions are scoped to the parent subcommands. See printNotFound.
+func errNotFound(cfg *Config, parent []string, parentCmd *Command, selected string, cmds []Command) error {
+	var suggestions []string
+	if cfg.Suggest != nil {
+		suggestions = cfg.Suggest(selected, cmds)
+	} else if suggestion := suggestCommand(selected, cmds); suggestion != "" {
+		suggestions = []string{suggestion}
+	}
+
+	err := ErrUnknownCommand{
+		Name:        selected,
+		Path:        strings.Join(append(parent[:len(parent):len(parent)], selected), " "),
+		Suggestions: suggestions,
+		parent:      parent,
+		parentCmd:   parentCmd,
+		cmds:        cmds,
+	}
+	if cfg.UsageErrorsToStderr {
+		return usageError{err: err, code: cfg.UsageErrorCode}
+	}
+	return err
+}
+
+// printNotFound prints the unknown command with suggestions and the usage hint,
+// the usage is scoped to the parent subcommands.
+func (r *Runner) printNotFound(e ErrUnknownCommand) {
+	cfg := &r.cfg
+	usageCfg := *cfg
+	if cfg.UsageErrorsToStderr {
+		usageCfg.Output = cfg.ErrOutput
+	}
+
+	w := usageCfg.Output
+	switch len(e.Suggestions) {
+	case 0:
+		fmt.Fprintf(w, "%q unknown command\n", e.Name)
+	case 1:
+		fmt.Fprintf(w, "%q unknown command, did you mean %q?\n", e.Name, e.Suggestions[0])
+	default:
+		quoted := make([]string, len(e.Suggestions))
+		for i, s := range e.Suggestions {
+			quoted[i] = strconv.Quote(s)
+		}
+		fmt.Fprintf(w, "%q unknown command, did you mean one of %s?\n", e.Name, strings.Join(quoted, ", "))
+	}
+	switch {
+	case cfg.HelpOnError && e.parentCmd == nil:
+		fmt.Fprintln(w)
+		cfg.Usage(usageCfg, e.cmds)
+	case cfg.HelpOnError:
+		fmt.Fprintln(w)
+		cfg.Usage(scopedConfig(usageCfg, e.parent, e.parentCmd), e.cmds)
+	default:
+		helpCmd := strings.Join(append([]string{cfg.AppName, "help"}, e.parent...), " ")
+		fmt.Fprintf(w, "Run %q for usage.\n\n", helpCmd)
 	}
-	fmt.Fprintf(w, "Run %q for usage.\n\n", appName+" help")
-	return fmt.Errorf("no such command %q", selected)
 }
 
+// usageError is an error caused by a wrong usage of the app, see Config.UsageErrorCode.
+type usageError struct {
+	err  error
+	code int
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+
 // suggestCommand for not found earlier command.
 func suggestCommand(got string, cmds []Command) string {
 	const maxMatchDist = 2
@@ -343,7 +1512,7 @@ func suggestCommand(got string, cmds []Command) string {
 	match := ""
 
 	for _, c := range cmds {
-		dist := strDistance(got, c.Name)
+		dist := strDistanceMax(got, c.Name, maxMatchDist)
 		if dist < minDist {
 			minDist = dist
 			match = c.Name
@@ -354,64 +1523,157 @@ func suggestCommand(got string, cmds []Command) string {
 
 func defaultUsage(r *Runner) func(cfg Config, cmds []Command) {
 	return func(cfg Config, cmds []Command) {
-		w := r.cfg.Output
-		if cfg.AppDescription != "" {
-			fmt.Fprintf(w, "%s\n\n", cfg.AppDescription)
-		}
+		printUsageWith(cfg, cmds, printCommands)
+	}
+}
 
-		fmt.Fprintf(w, "Usage:\n\n    %s <command> [arguments...]\n\nThe commands are:\n\n", cfg.AppName)
-		printCommands(&r.cfg, cmds)
+// DefaultUsage prints the default usage to w.
+// Useful in a custom Config.Usage to add sections (examples, links) after the default ones.
+func DefaultUsage(cfg Config, cmds []Command, w io.Writer) {
+	cfg.Output = w
+	printUsageWith(cfg, cmds, printCommands)
+}
 
-		if cfg.PostDescription != "" {
-			fmt.Fprintf(w, "%s\n\n", cfg.PostDescription)
-		}
-		if cfg.Version != "" {
-			fmt.Fprintf(w, "Version: %s\n\n", cfg.Version)
-		}
+// TreeUsage prints the usage like the default one but subcommands are shown
+// as an indented tree instead of "parent child" rows.
+// To use it set Config.Usage to acmd.TreeUsage.
+func TreeUsage(cfg Config, cmds []Command) {
+	printUsageWith(cfg, cmds, printCommandsTree)
+}
+
+// printUsageWith prints usage header and footer around the commands printed by printCmds.
+func printUsageWith(cfg Config, cmds []Command, printCmds func(cfg *Config, cmds []Command)) {
+	w := cfg.Output
+	if cfg.AppDescription != "" {
+		fmt.Fprintf(w, "%s\n\n", cfg.AppDescription)
+	}
+
+	fmt.Fprintf(w, "Usage:\n\n    %s <command> [arguments...]\n\nThe commands are:\n\n", cfg.AppName)
+	printCmds(&cfg, cmds)
+
+	if len(cfg.HelpTopics) != 0 {
+		fmt.Fprintf(w, "Additional help topics:\n\n")
+		printHelpTopics(&cfg)
+	}
+
+	if cfg.PostDescription != "" {
+		fmt.Fprintf(w, "%s\n\n", cfg.PostDescription)
+	}
+	if cfg.Version != "" {
+		fmt.Fprintf(w, "Version: %s\n\n", cfg.Version)
+	}
+}
+
+// printHelpTopics in a table form (Name and Title).
+func printHelpTopics(cfg *Config) {
+	t := newHelpTableFor(cfg)
+	for _, topic := range cfg.HelpTopics {
+		t.AddRow(topic.Name, topic.Title)
 	}
+	t.Render(cfg.Output)
+	fmt.Fprint(cfg.Output, "\n")
 }
 
 // printCommands in a table form (Name and Description).
 func printCommands(cfg *Config, cmds []Command) {
-	minwidth, tabwidth, padding, padchar, flags := 0, 0, 11, byte(' '), uint(0)
-	tw := tabwriter.NewWriter(cfg.Output, minwidth, tabwidth, padding, padchar, flags)
+	t := newHelpTableFor(cfg)
+
+	walkVisible(cfg, cmds, nil, func(path []string, cmd *Command) bool {
+		switch {
+		case len(cmd.Subcommands) == 0:
+			addCommandRow(cfg, t, strings.Join(path, " "), *cmd)
+		case len(path) == cfg.HelpMaxDepth:
+			hint := fmt.Sprintf("run %q for subcommands", cfg.AppName+" help "+strings.Join(path, " "))
+			t.AddRow(strings.Join(path, " ")+" …", hint)
+			return false
+		}
+		return true
+	})
+	t.Render(cfg.Output)
+	fmt.Fprint(cfg.Output, "\n")
+}
 
-	for _, cmd := range cmds {
-		if len(cmd.Subcommands) == 0 {
-			printCommand(cfg, tw, "", cmd)
+// printCommandsTree in a table form where subcommands are indented under the parent.
+func printCommandsTree(cfg *Config, cmds []Command) {
+	t := newHelpTableFor(cfg)
+
+	walkVisible(cfg, cmds, nil, func(path []string, cmd *Command) bool {
+		indent := strings.Repeat("    ", len(path)-1)
+		addCommandRow(cfg, t, indent+cmd.Name, *cmd)
+
+		if len(cmd.Subcommands) != 0 && len(path) == cfg.HelpMaxDepth {
+			hint := fmt.Sprintf("run %q for subcommands", cfg.AppName+" help "+strings.Join(path, " "))
+			t.AddRow(indent+"    …", hint)
+			return false
+		}
+		return true
+	})
+	t.Render(cfg.Output)
+	fmt.Fprint(cfg.Output, "\n")
+}
+
+// walkVisible calls fn for every visible command in depth-first order.
+// Hidden, unsupported or disabled command is skipped with all its subcommands,
+// as well as the command which lazy subcommands cannot be loaded.
+// Subcommands are skipped also if fn returns false.
+func walkVisible(cfg *Config, cmds []Command, parent []string, fn func(path []string, cmd *Command) bool) {
+	for i := range cmds {
+		cmd := &cmds[i]
+		if cmd.IsHidden || !cmd.isSupported() || cmd.checkEnabled(cfg) != nil {
+			continue
 		}
 
-		for _, subcmd := range cmd.Subcommands {
-			printCommand(cfg, tw, cmd.Name, subcmd)
+		path := append(parent[:len(parent):len(parent)], cmd.Name)
+		if cmd.loadSubcommands(cfg, path) != nil {
+			continue
+		}
+		if fn(path, cmd) {
+			walkVisible(cfg, cmd.Subcommands, path, fn)
 		}
 	}
-	fmt.Fprint(tw, "\n")
-	tw.Flush()
 }
 
-func printCommand(cfg *Config, tw *tabwriter.Writer, prefix string, cmd Command) {
-	if cmd.IsHidden {
-		return
+// newHelpTable returns a table in the style of the help.
+func newHelpTable() *Table {
+	return &Table{
+		Indent:  "    ",
+		Padding: 11,
+	}
+}
+
+// newHelpTableFor the output, descriptions are truncated to the terminal width unless Config.Wide.
+func newHelpTableFor(cfg *Config) *Table {
+	t := newHelpTable()
+	if !cfg.Wide {
+		t.Width = terminalWidth(cfg.Output)
 	}
+	return t
+}
 
-	name := cmd.Name
-	if prefix != "" {
-		name = fmt.Sprintf("%s %s", prefix, cmd.Name)
+func addCommandRow(cfg *Config, t *Table, name string, cmd Command) {
+	if cmd.IsHidden {
+		return
 	}
 
 	desc := cmd.Description
 	if desc == "" {
 		desc = "<no description>"
 	}
-
-	fmt.Fprintf(tw, "    %s\t%s\n", name, desc)
+	if cmd.Alias != "" {
+		name += " (" + cmd.Alias + ")"
+	}
+	t.AddRow(name, desc)
 
 	if cfg.VerboseHelp && cmd.FlagSet != nil {
+		buf := &bytes.Buffer{}
 		fset := cmd.FlagSet.Flags()
 		old := fset.Output()
-		fmt.Fprintf(tw, "        ")
-		fset.SetOutput(tw)
+		fset.SetOutput(buf)
 		fset.Usage()
 		fset.SetOutput(old)
+
+		for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+			t.AddRow("    " + line)
+		}
 	}
 }
@@ -4,13 +4,19 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -67,6 +73,89 @@ func TestRunner(t *testing.T) {
 	mustEqual(t, buf.String(), "for")
 }
 
+func TestRunnerResolve(t *testing.T) {
+	cmds := []Command{
+		{
+			Name: "test",
+			Subcommands: []Command{
+				{Name: "foo", Alias: "f", ExecFunc: nopFunc},
+				{Name: "bar", ExecFunc: nopFunc},
+			},
+		},
+		{Name: "status", ExecFunc: nopFunc},
+	}
+	buf := &bytes.Buffer{}
+	r := RunnerOf(cmds, Config{
+		Args:      []string{"./someapp", "status"},
+		Output:    buf,
+		ErrOutput: buf,
+	})
+
+	path, cmd, rest, err := r.Resolve([]string{"test", "f", "--all", "x"})
+	failIfErr(t, err)
+	mustEqual(t, path, []string{"test", "foo"})
+	mustEqual(t, cmd.Name, "foo")
+	mustEqual(t, rest, []string{"--all", "x"})
+
+	_, _, _, err = r.Resolve([]string{"test", "baz"})
+	var notFound ErrUnknownCommand
+	if !errors.As(err, &notFound) {
+		t.Fatal(err)
+	}
+	mustEqual(t, err.Error(), `no such command "test baz"`)
+	mustEqual(t, notFound.Name, "baz")
+	mustEqual(t, notFound.Path, "test baz")
+	mustEqual(t, notFound.Suggestions, []string{"bar"})
+	mustEqual(t, buf.String(), "")
+
+	_, cmd, _, err = r.Resolve([]string{""})
+	if !errors.As(err, &notFound) {
+		t.Fatal(err)
+	}
+	mustEqual(t, cmd, (*Command)(nil))
+	mustEqual(t, buf.String(), "")
+
+	_, _, _, err = r.Resolve(nil)
+	mustEqual(t, err, ErrNoArgs)
+}
+
+func TestRunnerResolveCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmds := []Command{
+		{
+			Name: "test",
+			Subcommands: []Command{
+				{Name: "foo", Alias: "f", ExecFunc: nopFunc},
+			},
+		},
+	}
+	r := RunnerOf(cmds, Config{
+		Args:   []string{"./someapp", "__resolve", "--no-pager", "test", "f", "--all", "x"},
+		Output: buf,
+	})
+	failIfErr(t, r.Run())
+
+	want := `global flags: ["--no-pager"]` + "\n" +
+		`command: "test foo"` + "\n" +
+		`params: ["--all" "x"]` + "\n"
+	mustEqual(t, buf.String(), want)
+
+	buf.Reset()
+	r = RunnerOf(cmds, Config{
+		Args:   []string{"./someapp", "__resolve", "--wide", "--output", "jsonl", "--seed", "5", "test", "f"},
+		Output: buf,
+	})
+	failIfErr(t, r.Run())
+
+	want = `global flags: ["--wide" "--output" "jsonl" "--seed" "5"]` + "\n" +
+		`command: "test foo"` + "\n" +
+		`params: []` + "\n"
+	mustEqual(t, buf.String(), want)
+	mustEqual(t, r.cfg.Wide, false)
+	mustEqual(t, r.cfg.EventOutput, nil)
+	mustEqual(t, r.hasSeed, false)
+}
+
 func TestRunnerMustSetDefaults(t *testing.T) {
 	app := "./someapp"
 	args := append([]string{app, "runner"}, os.Args[1:]...)
@@ -84,7 +173,7 @@ func TestRunnerMustSetDefaults(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	mustEqual(t, r.cfg.AppName, app)
+	mustEqual(t, r.cfg.AppName, "someapp")
 	if r.ctx == nil {
 		t.Fatal("context must be set")
 	}
@@ -117,6 +206,111 @@ func TestRunnerWithoutArgs(t *testing.T) {
 	mustEqual(t, err.Error(), "no args provided")
 }
 
+func TestRunnerSameNamesOnDifferentLevels(t *testing.T) {
+	cmds := []Command{
+		{Name: "add", ExecFunc: nopFunc},
+		{Name: "remote", Subcommands: []Command{{Name: "add", ExecFunc: nopFunc}}},
+	}
+	r := RunnerOf(cmds, Config{
+		Args:   []string{"./someapp", "remote", "add"},
+		Output: io.Discard,
+	})
+	failIfErr(t, r.Run())
+}
+
+func TestRunnerUnicodeNames(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmds := []Command{
+		{Name: "статус", ExecFunc: nopFunc},
+		{Name: "日本語", ExecFunc: nopFunc},
+	}
+	r := RunnerOf(cmds, Config{
+		Args:              []string{"./someapp", "стутус"},
+		AppName:           "myapp",
+		Output:            buf,
+		AllowUnicodeNames: true,
+	})
+
+	err := r.Run()
+	failIfOk(t, err)
+	mustEqual(t, err.Error(), `no such command "стутус"`)
+
+	want := `"стутус" unknown command, did you mean "статус"?` + "\n" + `Run "myapp help" for usage.` + "\n\n"
+	mustEqual(t, buf.String(), want)
+}
+
+func TestRunnerNoPagerFlag(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmds := []Command{{Name: "foo", ExecFunc: nopFunc}}
+	r := RunnerOf(cmds, Config{
+		Args:     []string{"./someapp", "--no-pager", "help"},
+		AppName:  "myapp",
+		Output:   buf,
+		UsePager: true,
+	})
+	failIfErr(t, r.Run())
+
+	mustEqual(t, r.noPager, true)
+	if !strings.Contains(buf.String(), "shows help message") {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestRunnerSelectCommand(t *testing.T) {
+	var got string
+	cmds := []Command{
+		{Name: "foo", Description: "does foo", ExecFunc: nopFunc},
+		{
+			Name: "time", Subcommands: []Command{
+				{Name: "next", ExecFunc: func(ctx context.Context, args []string) error {
+					got = "next"
+					return nil
+				}},
+				{Name: "curr", ExecFunc: nopFunc},
+			},
+		},
+	}
+
+	testCases := []struct {
+		input string
+		want  []string
+	}{
+		{"1\n", []string{"foo"}},
+		{"does\n", []string{"foo"}},
+		{"time\n2\n", []string{"time", "next"}},
+		{"nope\n9\n4\n", []string{"time", "next"}},
+	}
+
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		r := RunnerOf(cmds, Config{
+			Args:   []string{"./someapp", "foo"},
+			Output: buf,
+			Input:  strings.NewReader(tc.input),
+		})
+
+		args, err := r.selectCommand()
+		failIfErr(t, err)
+		mustEqual(t, args, tc.want)
+	}
+
+	r := RunnerOf(cmds, Config{
+		Args:   []string{"./someapp", "foo"},
+		Output: io.Discard,
+		Input:  strings.NewReader("time next\n"),
+	})
+	r.needsSelect = true
+	failIfErr(t, r.Run())
+	mustEqual(t, got, "next")
+
+	_, err := RunnerOf(cmds, Config{
+		Args:   []string{"./someapp", "foo"},
+		Output: io.Discard,
+		Input:  strings.NewReader(""),
+	}).selectCommand()
+	mustEqual(t, err, ErrNoArgs)
+}
+
 func TestRunnerMustSortCommands(t *testing.T) {
 	cmds := []Command{
 		{Name: "foo", ExecFunc: nopFunc},
@@ -165,8 +359,9 @@ func TestRunnerJustExit(t *testing.T) {
 
 	buf := &bytes.Buffer{}
 	r := RunnerOf([]Command{{Name: "foo", ExecFunc: nopFunc}}, Config{
-		AppName: "exit-test",
-		Output:  buf,
+		AppName:   "exit-test",
+		Output:    io.Discard,
+		ErrOutput: buf,
 	})
 	r.Exit(nil)
 
@@ -194,15 +389,15 @@ func TestRunnerInit(t *testing.T) {
 		},
 		{
 			cmds:       []Command{{Name: "", ExecFunc: nopFunc}},
-			wantErrStr: `command "" must contains only letters, digits, - and _`,
+			wantErrStr: `command "" must contains only letters, digits, -, _, : and .`,
 		},
 		{
 			cmds:       []Command{{Name: "foo%", ExecFunc: nopFunc}},
-			wantErrStr: `command "foo%" must contains only letters, digits, - and _`,
+			wantErrStr: `command "foo%" must contains only letters, digits, -, _, : and .`,
 		},
 		{
 			cmds:       []Command{{Name: "foo", Alias: "%", ExecFunc: nopFunc}},
-			wantErrStr: `command alias "%" must contains only letters, digits, - and _`,
+			wantErrStr: `command alias "%" must contains only letters, digits, -, _, : and .`,
 		},
 		{
 			cmds:       []Command{{Name: "foo%", ExecFunc: nil}},
@@ -235,6 +430,10 @@ func TestRunnerInit(t *testing.T) {
 			cmds:       []Command{{Name: "version", ExecFunc: nopFunc}},
 			wantErrStr: `command "version" is reserved`,
 		},
+		{
+			cmds:       []Command{{Name: "__resolve", ExecFunc: nopFunc}},
+			wantErrStr: `command "__resolve" is reserved`,
+		},
 		{
 			cmds:       []Command{{Name: "foo", Alias: "help", ExecFunc: nopFunc}},
 			wantErrStr: `command alias "help" is reserved`,
@@ -259,6 +458,37 @@ func TestRunnerInit(t *testing.T) {
 			cmds:       []Command{{Name: "a", ExecFunc: nopFunc}, {Name: "b", Alias: "a", ExecFunc: nopFunc}},
 			wantErrStr: `duplicate command alias "a"`,
 		},
+		{
+			cmds:       []Command{{Name: "rm", ExecFunc: nopFunc}},
+			cfg:        Config{BannedCommandNames: []string{"rm", "delete"}},
+			wantErrStr: `command "rm" is banned`,
+		},
+		{
+			cmds:       []Command{{Name: "remove", Alias: "rm", ExecFunc: nopFunc}},
+			cfg:        Config{BannedCommandNames: []string{"rm", "delete"}},
+			wantErrStr: `command alias "rm" is banned`,
+		},
+		{
+			cmds: []Command{{Name: "foo", Subcommands: []Command{
+				{Name: "Bar", ExecFunc: nopFunc},
+			}}},
+			cfg:        Config{NamePattern: regexp.MustCompile(`^[a-z]+$`)},
+			wantErrStr: `command "Bar" must match "^[a-z]+$"`,
+		},
+		{
+			cmds:       []Command{{Name: "rm -rf", ExecFunc: nopFunc}},
+			cfg:        Config{NamePattern: regexp.MustCompile(`[a-z]+`)},
+			wantErrStr: `command "rm -rf" must match "[a-z]+"`,
+		},
+		{
+			cmds:       []Command{{Name: "ab", Alias: "a-b", ExecFunc: nopFunc}},
+			cfg:        Config{NamePattern: regexp.MustCompile(`a|ab`)},
+			wantErrStr: `command alias "a-b" must match "a|ab"`,
+		},
+		{
+			cmds:       []Command{{Name: "статус", ExecFunc: nopFunc}},
+			wantErrStr: `command "статус" must contains only letters, digits, -, _, : and .`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -272,6 +502,146 @@ func TestRunnerInit(t *testing.T) {
 	}
 }
 
+func TestAmbiguityReport(t *testing.T) {
+	testCases := []struct {
+		cmds []Command
+		want []string
+	}{
+		{
+			cmds: []Command{
+				{Name: "foo", Alias: "b", ExecFunc: nopFunc},
+				{Name: "bar", Subcommands: []Command{{Name: "b", ExecFunc: nopFunc}}},
+			},
+			want: []string{`"b" of "bar b" shadows "foo" (alias)`},
+		},
+		{
+			cmds: []Command{
+				{Name: "foo", ExecFunc: nopFunc},
+				{Name: "bar", Subcommands: []Command{{Name: "baz", Alias: "foo", ExecFunc: nopFunc}}},
+			},
+			want: []string{`"foo" of "bar baz" (alias) shadows "foo"`},
+		},
+		{
+			cmds: []Command{
+				{Name: "foo", ExecFunc: nopFunc},
+				{Name: "bar", Subcommands: []Command{
+					{Name: "baz", Subcommands: []Command{{Name: "qux", Alias: "foo", ExecFunc: nopFunc}}},
+				}},
+			},
+			want: []string{`"foo" of "bar baz qux" (alias) shadows "foo"`},
+		},
+		{
+			cmds: []Command{
+				{Name: "foo", Subcommands: []Command{
+					{Name: "baz", Alias: "z", ExecFunc: nopFunc},
+					{Name: "qux", Subcommands: []Command{{Name: "z", ExecFunc: nopFunc}}},
+				}},
+				{Name: "bar", Subcommands: []Command{{Name: "z", ExecFunc: nopFunc}}},
+			},
+			want: []string{`"z" of "foo qux z" shadows "foo baz" (alias)`},
+		},
+		{
+			cmds: []Command{
+				{Name: "build", Alias: "b", ExecFunc: nopFunc},
+				{Name: "foo", Subcommands: []Command{{Name: "bar", Alias: "b", ExecFunc: nopFunc}}},
+			},
+			want: []string{`"b" of "foo bar" (alias) shadows "build" (alias)`},
+		},
+		{
+			cmds: []Command{
+				{Name: "add", ExecFunc: nopFunc},
+				{Name: "remote", Subcommands: []Command{{Name: "add", ExecFunc: nopFunc}}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		r := RunnerOf(tc.cmds, Config{Args: []string{"./someapp", "help"}, Output: io.Discard})
+		failIfErr(t, r.Run())
+		mustEqual(t, r.AmbiguityReport(), tc.want)
+	}
+}
+
+func TestRunnerInitErrorTypes(t *testing.T) {
+	run := func(cmds []Command, cfg Config) error {
+		cfg.Args = []string{"./someapp", "foo"}
+		cfg.Output = io.Discard
+		return RunnerOf(cmds, cfg).Run()
+	}
+
+	err := run([]Command{{Name: "time", Subcommands: []Command{
+		{Name: "next", Alias: "help", ExecFunc: nopFunc},
+	}}}, Config{})
+	var errReserved ErrReservedName
+	if !errors.As(err, &errReserved) {
+		t.Fatal(err)
+	}
+	mustEqual(t, errReserved, ErrReservedName{Name: "help", Path: "time next", IsAlias: true})
+
+	err = run([]Command{{Name: "time", Subcommands: []Command{
+		{Name: "next", ExecFunc: nopFunc},
+		{Name: "prev", Alias: "next", ExecFunc: nopFunc},
+	}}}, Config{})
+	var errDuplicate ErrDuplicateCommand
+	if !errors.As(err, &errDuplicate) {
+		t.Fatal(err)
+	}
+	mustEqual(t, errDuplicate, ErrDuplicateCommand{Name: "next", Path: "time prev", IsAlias: true})
+
+	err = run([]Command{{Name: "rm", ExecFunc: nopFunc}}, Config{BannedCommandNames: []string{"rm"}})
+	var errInvalid ErrInvalidName
+	if !errors.As(err, &errInvalid) {
+		t.Fatal(err)
+	}
+	mustEqual(t, errInvalid, ErrInvalidName{Name: "rm", Path: "rm", Rule: "is banned"})
+}
+
+func TestRunner_suggestSubcommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmds := []Command{
+		{Name: "test", Subcommands: []Command{
+			{Name: "unit", ExecFunc: nopFunc},
+			{Name: "e2e", ExecFunc: nopFunc},
+		}},
+		{Name: "oops", ExecFunc: nopFunc},
+	}
+	r := RunnerOf(cmds, Config{
+		AppName: "myapp",
+		Args:    []string{"./myapp", "test", "uni"},
+		Output:  buf,
+	})
+
+	err := r.Run()
+	failIfOk(t, err)
+	mustEqual(t, err.Error(), `no such command "test uni"`)
+
+	want := `"uni" unknown command, did you mean "unit"?` + "\n" + `Run "myapp help test" for usage.` + "\n\n"
+	mustEqual(t, buf.String(), want)
+}
+
+func TestRunner_helpOnError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmds := []Command{
+		{Name: "test", Description: "runs tests", Subcommands: []Command{
+			{Name: "unit", Description: "unit tests", ExecFunc: nopFunc},
+		}},
+	}
+	r := RunnerOf(cmds, Config{
+		AppName:     "myapp",
+		Args:        []string{"./myapp", "test", "oops"},
+		Output:      buf,
+		HelpOnError: true,
+	})
+	failIfOk(t, r.Run())
+
+	want := `"oops" unknown command` + "\n\n" +
+		"runs tests\n\n" +
+		"Usage:\n\n    myapp test <command> [arguments...]\n\n" +
+		"The commands are:\n\n" +
+		"    unit           unit tests\n\n"
+	mustEqual(t, buf.String(), want)
+}
+
 func TestRunner_suggestCommand(t *testing.T) {
 	testCases := []struct {
 		cmds []Command
@@ -320,6 +690,104 @@ func TestRunner_suggestCommand(t *testing.T) {
 	}
 }
 
+func TestRunner_customSuggest(t *testing.T) {
+	cmds := []Command{
+		{Name: "status", ExecFunc: nopFunc},
+		{Name: "stats", ExecFunc: nopFunc},
+	}
+
+	testCases := []struct {
+		suggest func(input string, cmds []Command) []string
+		want    string
+	}{
+		{
+			suggest: func(input string, cmds []Command) []string { return nil },
+			want:    `"st" unknown command` + "\n",
+		},
+		{
+			suggest: func(input string, cmds []Command) []string { return []string{"status"} },
+			want:    `"st" unknown command, did you mean "status"?` + "\n",
+		},
+		{
+			suggest: func(input string, cmds []Command) []string {
+				var res []string
+				for _, c := range cmds {
+					if strings.HasPrefix(c.Name, input) {
+						res = append(res, c.Name)
+					}
+				}
+				return res
+			},
+			want: `"st" unknown command, did you mean one of "stats", "status"?` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		r := RunnerOf(cmds, Config{
+			Args:    []string{"./someapp", "st"},
+			AppName: "myapp",
+			Output:  buf,
+			Suggest: tc.suggest,
+		})
+		failIfOk(t, r.Run())
+		mustEqual(t, buf.String(), tc.want+`Run "myapp help" for usage.`+"\n\n")
+	}
+}
+
+func TestRunnerHelpTopic(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cfg := Config{
+		Args:   []string{"./someapp", "help", "auth"},
+		Output: buf,
+		HelpTopics: []HelpTopic{
+			{Name: "auth", Title: "authentication setup", Content: "\nRun login.\n"},
+		},
+	}
+	r := RunnerOf([]Command{{Name: "login", ExecFunc: nopFunc}}, cfg)
+	failIfErr(t, r.Run())
+	mustEqual(t, buf.String(), "authentication setup\n\nRun login.\n\n")
+
+	cfg.Args = []string{"./someapp", "help", "nope"}
+	err := RunnerOf([]Command{{Name: "login", ExecFunc: nopFunc}}, cfg).Run()
+	failIfOk(t, err)
+	mustEqual(t, err.Error(), `unknown help topic or command "nope"`)
+}
+
+func TestRunnerHelpCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmds := []Command{
+		{
+			Name: "time", Subcommands: []Command{
+				{Name: "set", Alias: "s", ExecFunc: nopFunc, Description: "sets time", FlagSet: &testFlags{}},
+			},
+		},
+	}
+	r := RunnerOf(cmds, Config{
+		Args:    []string{"./someapp", "help", "time", "s"},
+		AppName: "myapp",
+		Output:  buf,
+	})
+	failIfErr(t, r.Run())
+
+	want := "sets time\n\n" +
+		"Usage:\n\n    myapp time set [arguments...]\n\n" +
+		"Alias: s\n\n" +
+		"Flags:\n\n" +
+		"  -zone string\n    \ttime zone (default \"UTC\")\n\n"
+	mustEqual(t, buf.String(), want)
+}
+
+type testFlags struct {
+	Zone string
+}
+
+func (f *testFlags) Flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.StringVar(&f.Zone, "zone", "UTC", "time zone")
+	return fs
+}
+
 func TestHasHelpFlag(t *testing.T) {
 	testCases := []struct {
 		args    []string
@@ -354,30 +822,303 @@ func TestCommand_IsHidden(t *testing.T) {
 	}
 }
 
-func TestExit(t *testing.T) {
-	wantStatus := 42
-	wantOutput := "myapp: code 42\n"
-
+func TestCommand_IsHiddenSubtree(t *testing.T) {
+	buf := &bytes.Buffer{}
 	cmds := []Command{
+		{Name: "for", ExecFunc: nopFunc},
 		{
-			Name: "for",
-			ExecFunc: func(ctx context.Context, args []string) error {
-				return ErrCode(wantStatus)
+			Name: "secret", IsHidden: true, Subcommands: []Command{
+				{Name: "alpha", ExecFunc: nopFunc},
+			},
+		},
+		{
+			Name: "public", Subcommands: []Command{
+				{Name: "beta", ExecFunc: nopFunc},
+				{Name: "gamma", ExecFunc: nopFunc, IsHidden: true},
+				{
+					Name: "deep", Subcommands: []Command{
+						{Name: "delta", ExecFunc: nopFunc},
+					},
+				},
 			},
 		},
 	}
 
+	for _, usage := range []func(cfg Config, cmds []Command){nil, TreeUsage} {
+		buf.Reset()
+		r := RunnerOf(cmds, Config{
+			Args:    []string{"./someapp", "help"},
+			AppName: "myapp",
+			Output:  buf,
+			Usage:   usage,
+		})
+		failIfErr(t, r.Run())
+
+		out := buf.String()
+		for _, hidden := range []string{"secret", "alpha", "gamma"} {
+			if strings.Contains(out, hidden) {
+				t.Fatalf("should not show %q in:\n%s", hidden, out)
+			}
+		}
+		for _, visible := range []string{"beta", "delta"} {
+			if !strings.Contains(out, visible) {
+				t.Fatalf("should show %q in:\n%s", visible, out)
+			}
+		}
+	}
+	if !strings.Contains(buf.String(), "deep") {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestCommand_Platforms(t *testing.T) {
+	defer func(os, arch string) { goos, goarch = os, arch }(goos, goarch)
+	goos, goarch = "windows", "amd64"
+
+	cmds := []Command{
+		{Name: "anywhere", ExecFunc: nopFunc},
+		{Name: "linuxonly", ExecFunc: nopFunc, Platforms: []string{"linux", "darwin/arm64"}},
+		{Name: "winamd", ExecFunc: nopFunc, Platforms: []string{"windows/amd64"}},
+	}
+
 	buf := &bytes.Buffer{}
 	r := RunnerOf(cmds, Config{
-		AppName: "myapp",
-		Args:    []string{"./someapp", "for"},
-		Output:  buf,
+		Args:   []string{"./someapp", "help"},
+		Output: buf,
 	})
+	failIfErr(t, r.Run())
 
-	err := r.Run()
+	if out := buf.String(); strings.Contains(out, "linuxonly") || !strings.Contains(out, "winamd") {
+		t.Fatal(out)
+	}
+
+	_, _, _, err := r.Resolve([]string{"linuxonly"})
 	failIfOk(t, err)
+	mustEqual(t, err.Error(), `command "linuxonly" is not supported on windows/amd64`)
 
-	var gotStatus int
+	_, _, _, err = r.Resolve([]string{"winamd"})
+	failIfErr(t, err)
+}
+
+func TestCommand_Requires(t *testing.T) {
+	defer func(f func() int) { geteuid = f }(geteuid)
+
+	errNoDocker := errors.New("docker is not available")
+	var called bool
+	cmds := []Command{
+		{
+			Name:         "install",
+			RequiresRoot: true,
+			ExecFunc: func(ctx context.Context, args []string) error {
+				called = true
+				return nil
+			},
+		},
+		{
+			Name: "build",
+			RequiresCapability: func(ctx context.Context) error {
+				return errNoDocker
+			},
+			ExecFunc: nopFunc,
+		},
+	}
+
+	geteuid = func() int { return 1000 }
+	err := RunnerOf(cmds, Config{Args: []string{"./someapp", "install"}, Output: io.Discard}).Run()
+	if !errors.Is(err, ErrRequiresRoot) {
+		t.Fatal(err)
+	}
+	mustEqual(t, err.Error(), `command "install": must be run as root, try again with sudo`)
+	mustEqual(t, called, false)
+
+	geteuid = func() int { return 0 }
+	err = RunnerOf(cmds, Config{Args: []string{"./someapp", "install"}, Output: io.Discard}).Run()
+	failIfErr(t, err)
+	mustEqual(t, called, true)
+
+	err = RunnerOf(cmds, Config{Args: []string{"./someapp", "build"}, Output: io.Discard}).Run()
+	mustEqual(t, err, errNoDocker)
+}
+
+func TestRunnerHistory(t *testing.T) {
+	defer func(f func() (string, error)) { userCacheDir = f }(userCacheDir)
+	dir := t.TempDir()
+	userCacheDir = func() (string, error) { return dir, nil }
+
+	var calls []string
+	cmds := []Command{
+		{
+			Name: "foo",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				calls = append(calls, strings.Join(args, ","))
+				return nil
+			},
+		},
+		{
+			Name: "fail",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				return ErrCode(3)
+			},
+		},
+	}
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		r := RunnerOf(cmds, Config{
+			Args:    append([]string{"./someapp"}, args...),
+			AppName: "/usr/bin/myapp",
+			Output:  buf,
+			History: true,
+		})
+		err := r.Run()
+		return buf.String(), err
+	}
+
+	_, err := run("foo", "a", "b")
+	failIfErr(t, err)
+	_, err = run("fail")
+	failIfOk(t, err)
+
+	out, err := run("history")
+	failIfErr(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	mustEqual(t, len(lines), 2)
+	if !strings.HasSuffix(lines[0], "  0  foo a b") || !strings.HasSuffix(lines[1], "  3  fail") {
+		t.Fatal(out)
+	}
+
+	_, err = run("history", "rerun", "1")
+	failIfErr(t, err)
+	mustEqual(t, calls, []string{"a,b", "a,b"})
+
+	_, err = run("history", "rerun", "42")
+	failIfOk(t, err)
+
+	if _, err := os.Stat(filepath.Join(dir, "myapp", "history")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunnerHistory_redact(t *testing.T) {
+	defer func(f func() (string, error)) { userCacheDir = f }(userCacheDir)
+	dir := t.TempDir()
+	userCacheDir = func() (string, error) { return dir, nil }
+
+	cmds := []Command{
+		{Name: "login", ExecFunc: nopFunc},
+		{Name: "echo", ExecFunc: nopFunc},
+	}
+	redact := func(args []string) []string {
+		if args[0] == "echo" && len(args) > 1 && args[1] == "secret" {
+			return nil
+		}
+		for i, arg := range args {
+			if strings.HasPrefix(arg, "--token=") {
+				args[i] = "--token=***"
+			}
+		}
+		return args
+	}
+	run := func(args ...string) string {
+		buf := &bytes.Buffer{}
+		r := RunnerOf(cmds, Config{
+			Args:          append([]string{"./someapp"}, args...),
+			AppName:       "myapp",
+			Output:        buf,
+			History:       true,
+			HistoryRedact: redact,
+		})
+		failIfErr(t, r.Run())
+		return buf.String()
+	}
+
+	long := strings.Repeat("x", 100<<10)
+	run("login", "--token=abc123")
+	run("echo", "secret")
+	run("echo", long)
+
+	out := run("history")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	mustEqual(t, len(lines), 2)
+	if !strings.HasSuffix(lines[0], "  0  login --token=***") || !strings.HasSuffix(lines[1], "  0  echo "+long) {
+		t.Fatal(out)
+	}
+}
+
+func TestRunnerFirstRun(t *testing.T) {
+	defer func(f func() (string, error)) { userConfigDir = f }(userConfigDir)
+	dir := t.TempDir()
+	userConfigDir = func() (string, error) { return dir, nil }
+
+	errConsent := errors.New("no consent")
+	var calls int
+	firstRun := func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			return errConsent
+		}
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		r := RunnerOf([]Command{{Name: "foo", ExecFunc: nopFunc}}, Config{
+			Args:     []string{"./someapp", "foo"},
+			AppName:  "myapp",
+			Output:   io.Discard,
+			FirstRun: firstRun,
+		})
+
+		err := r.Run()
+		if i == 0 {
+			mustEqual(t, err, errConsent)
+		} else {
+			failIfErr(t, err)
+		}
+	}
+	mustEqual(t, calls, 2)
+
+	if _, err := os.Stat(filepath.Join(dir, "myapp", ".first-run")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExitWithoutInit(t *testing.T) {
+	var gotStatus int
+	doExitOld := func(code int) {
+		gotStatus = code
+	}
+	defer func() { doExit = doExitOld }()
+	doExitOld, doExit = doExit, doExitOld
+
+	r := &Runner{}
+	r.Exit(ErrCode(7))
+	mustEqual(t, gotStatus, 7)
+}
+
+func TestExit(t *testing.T) {
+	wantStatus := 42
+	wantOutput := "myapp: code 42\n"
+
+	cmds := []Command{
+		{
+			Name: "for",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				return ErrCode(wantStatus)
+			},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	r := RunnerOf(cmds, Config{
+		AppName:   "myapp",
+		Args:      []string{"./someapp", "for"},
+		Output:    io.Discard,
+		ErrOutput: buf,
+	})
+
+	err := r.Run()
+	failIfOk(t, err)
+
+	var gotStatus int
 	doExitOld := func(code int) {
 		gotStatus = code
 	}
@@ -391,6 +1132,931 @@ func TestExit(t *testing.T) {
 	mustEqual(t, buf.String(), wantOutput)
 }
 
+func TestRunner_credits(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := RunnerOf([]Command{{Name: "foo", ExecFunc: nopFunc}}, Config{
+		Args:     []string{"./someapp", "credits"},
+		Output:   buf,
+		Licenses: "\nfoo: MIT License\n",
+	})
+	failIfErr(t, r.Run())
+
+	if !strings.HasPrefix(buf.String(), "foo: MIT License\n\n") {
+		t.Fatalf("got %q", buf.String())
+	}
+
+	r = RunnerOf([]Command{{Name: "credits", ExecFunc: nopFunc}}, Config{
+		Args:     []string{"./someapp", "credits"},
+		Output:   io.Discard,
+		Licenses: "foo: MIT License",
+	})
+	var errReserved ErrReservedName
+	if !errors.As(r.Run(), &errReserved) {
+		t.Fatal("credits must be reserved")
+	}
+}
+
+func TestRunner_usageErrorsToStderr(t *testing.T) {
+	var gotStatus int
+	doExitOld := func(code int) {
+		gotStatus = code
+	}
+	defer func() { doExit = doExitOld }()
+	doExitOld, doExit = doExit, doExitOld
+
+	for _, code := range []int{0, 64} {
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		r := RunnerOf([]Command{{Name: "foo", ExecFunc: nopFunc}}, Config{
+			AppName:             "myapp",
+			Args:                []string{"./myapp", "fooo"},
+			Output:              out,
+			ErrOutput:           errOut,
+			UsageErrorsToStderr: true,
+			UsageErrorCode:      code,
+		})
+		err := r.Run()
+		failIfOk(t, err)
+		r.Exit(err)
+
+		wantCode := code
+		if code == 0 {
+			wantCode = 2
+		}
+		mustEqual(t, gotStatus, wantCode)
+		mustEqual(t, out.String(), "")
+		mustEqual(t, errOut.String(), `"fooo" unknown command, did you mean "foo"?`+"\n"+
+			`Run "myapp help" for usage.`+"\n\n"+
+			`myapp: no such command "fooo"`+"\n")
+	}
+}
+
+func TestRunner_experimentalCommands(t *testing.T) {
+	enabled := false
+	cmds := func() []Command {
+		return []Command{
+			{Name: "beta", Description: "beta feature", Experimental: true, ExecFunc: nopFunc},
+			{Name: "gated", Description: "gated feature", EnabledIf: func() bool { return enabled }, ExecFunc: nopFunc},
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	r := RunnerOf(cmds(), Config{AppName: "my-app", Args: []string{"./my-app", "help"}, Output: buf})
+	failIfErr(t, r.Run())
+	if strings.Contains(buf.String(), "beta") || strings.Contains(buf.String(), "gated") {
+		t.Fatal(buf.String())
+	}
+
+	r = RunnerOf(cmds(), Config{AppName: "my-app", Args: []string{"./my-app", "beta"}, Output: io.Discard})
+	mustEqual(t, r.Run().Error(), `command "beta" is experimental, enable with MY_APP_EXPERIMENTAL=1`)
+
+	r = RunnerOf(cmds(), Config{AppName: "my-app", Args: []string{"./my-app", "gated"}, Output: io.Discard})
+	mustEqual(t, r.Run().Error(), `command "gated" is not enabled`)
+
+	t.Setenv("MY_APP_EXPERIMENTAL", "1")
+	enabled = true
+
+	buf.Reset()
+	r = RunnerOf(cmds(), Config{AppName: "my-app", Args: []string{"./my-app", "help"}, Output: buf})
+	failIfErr(t, r.Run())
+	if !strings.Contains(buf.String(), "beta feature") || !strings.Contains(buf.String(), "gated feature") {
+		t.Fatal(buf.String())
+	}
+
+	r = RunnerOf(cmds(), Config{AppName: "my-app", Args: []string{"./my-app", "beta"}, Output: io.Discard})
+	failIfErr(t, r.Run())
+}
+
+func TestRunner_argChoices(t *testing.T) {
+	fset := flag.NewFlagSet("export", flag.ContinueOnError)
+	fset.String("o", "", "")
+
+	cmds := []Command{{
+		Name:       "export",
+		ExecFunc:   nopFunc,
+		ArgChoices: [][]string{{"users", "groups"}, nil, {"json", "yaml"}},
+		FlagSet:    &copyCmd{fset: fset},
+	}}
+	run := func(args ...string) error {
+		r := RunnerOf(cmds, Config{Args: append([]string{"./myapp", "export"}, args...), Output: io.Discard})
+		return r.Run()
+	}
+
+	failIfErr(t, run("users", "-o", "out.txt", "anything", "yaml"))
+	failIfErr(t, run("groups"))
+
+	err := run("-o", "users", "roles")
+	mustEqual(t, err.Error(), `command "export": invalid value "roles" for arg 1, expected one of "users", "groups"`)
+
+	err = run("users", "x", "--", "-xml")
+	mustEqual(t, err.Error(), `command "export": invalid value "-xml" for arg 3, expected one of "json", "yaml"`)
+}
+
+func TestRunner_kvArgs(t *testing.T) {
+	fset := flag.NewFlagSet("set", flag.ContinueOnError)
+	fset.String("label", "", "")
+	fset.Bool("v", false, "")
+
+	cmds := []Command{{
+		Name:     "set",
+		ExecFunc: nopFunc,
+		KVArgs:   true,
+		FlagSet:  &copyCmd{fset: fset},
+	}}
+	run := func(args ...string) error {
+		r := RunnerOf(cmds, Config{Args: append([]string{"./myapp", "set"}, args...), Output: io.Discard})
+		return r.Run()
+	}
+
+	failIfErr(t, run("replicas=3", "--label", "a=b", "-v", "image=foo"))
+
+	err := run("replicas=3", "image")
+	mustEqual(t, err.Error(), `command "set": arg "image" is not a key=value pair`)
+
+	err = run("replicas=3", "=foo")
+	mustEqual(t, err.Error(), `command "set": malformed pair "=foo" at arg 2: key cannot be empty`)
+}
+
+func TestMount(t *testing.T) {
+	subCmds := []Command{
+		{Name: "lint", Description: "lints the code", ExecFunc: nopFunc},
+		{Name: "fmt", Description: "formats the code", ExecFunc: nopFunc},
+	}
+
+	buf := &bytes.Buffer{}
+	r := RunnerOf([]Command{Mount("subtool", "code tools", subCmds)}, Config{
+		AppName: "bigtool",
+		Args:    []string{"./bigtool", "help", "subtool", "lint"},
+		Output:  buf,
+	})
+	failIfErr(t, r.Run())
+
+	mustEqual(t, buf.String(), "lints the code\n\nUsage:\n\n    bigtool subtool lint [arguments...]\n\n")
+	mustEqual(t, subCmds[0].Name, "lint") // not sorted in place.
+}
+
+func TestRunner_multiCall(t *testing.T) {
+	var got []string
+	cmds := []Command{{
+		Name:  "status",
+		Alias: "st",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			got = args
+			return nil
+		},
+	}}
+
+	for _, exe := range []string{"/usr/local/bin/status", "st.exe"} {
+		got = nil
+		r := RunnerOf(cmds, Config{
+			AppName:   "myapp",
+			Args:      []string{exe, "--no-pager", "-v"},
+			Output:    io.Discard,
+			MultiCall: true,
+		})
+		failIfErr(t, r.Run())
+		mustEqual(t, got, []string{"--no-pager", "-v"})
+		mustEqual(t, r.noPager, false)
+	}
+
+	got = nil
+	r := RunnerOf(cmds, Config{
+		Args:      []string{"/usr/bin/status", "--output", "jsonl", "--seed", "5"},
+		Output:    io.Discard,
+		MultiCall: true,
+	})
+	failIfErr(t, r.Run())
+	mustEqual(t, got, []string{"--output", "jsonl", "--seed", "5"})
+	mustEqual(t, r.cfg.EventOutput, nil)
+	mustEqual(t, r.hasSeed, false)
+
+	r = RunnerOf(cmds, Config{
+		Args:      []string{"./myapp", "status", "-v"},
+		Output:    io.Discard,
+		MultiCall: true,
+	})
+	failIfErr(t, r.Run())
+	mustEqual(t, got, []string{"-v"})
+}
+
+func TestRunner_helpIndex(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmds := []Command{
+		{Name: "now", Description: "prints current time", ExecFunc: nopFunc},
+		{Name: "secret", IsHidden: true, ExecFunc: nopFunc},
+		{Name: "time", Description: "time commands", Subcommands: []Command{
+			{Name: "next", Description: "next\ttime", ExecFunc: nopFunc},
+		}},
+	}
+	r := RunnerOf(cmds, Config{Args: []string{"./myapp", "help", "--index"}, Output: buf})
+	failIfErr(t, r.Run())
+
+	want := "help\thelp\tshows help message\n" +
+		"now\tnow\tprints current time\n" +
+		"time\ttime\ttime commands\n" +
+		"next\ttime next\tnext time\n" +
+		"version\tversion\tshows version of the application\n"
+	mustEqual(t, buf.String(), want)
+}
+
+func TestRunner_PreprocessArgs(t *testing.T) {
+	var got []string
+	cmds := []Command{{
+		Name: "deploy",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			got = args
+			return nil
+		},
+	}}
+
+	args := []string{"./myapp", "-nopager", "push", "-env=prod"}
+	r := RunnerOf(cmds, Config{
+		Args:   args,
+		Output: io.Discard,
+		PreprocessArgs: func(args []string) []string {
+			for i, arg := range args {
+				switch {
+				case arg == "-nopager":
+					args[i] = "--no-pager"
+				case arg == "push":
+					args[i] = "deploy"
+				case strings.HasPrefix(arg, "-env="):
+					args[i] = "--environment=" + strings.TrimPrefix(arg, "-env=")
+				}
+			}
+			return args
+		},
+	})
+	failIfErr(t, r.Run())
+
+	mustEqual(t, got, []string{"--environment=prod"})
+	mustEqual(t, r.noPager, true)
+	mustEqual(t, args, []string{"./myapp", "-nopager", "push", "-env=prod"})
+}
+
+func TestRunner_which(t *testing.T) {
+	cmds := []Command{
+		{Name: "status", Alias: "st", ExecFunc: nopFunc},
+		{Name: "time", Subcommands: []Command{
+			{Name: "next", Alias: "n", ExecFunc: nopFunc},
+		}},
+	}
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		r := RunnerOf(cmds, Config{
+			Args:      append([]string{"./myapp", "which"}, args...),
+			Output:    buf,
+			Which:     true,
+			Providers: []CommandProvider{&testProvider{remote: []string{"deploy"}}},
+		})
+		err := r.Run()
+		return buf.String(), err
+	}
+
+	for args, want := range map[string]string{
+		"status":    "status: command\n",
+		"st":        "st: alias of \"status\"\n",
+		"time n":    "time n: alias of \"time next\"\n",
+		"help":      "help: built-in command\n",
+		"which":     "which: built-in command\n",
+		"deploy":    "deploy: command from provider 1\n",
+		"time next": "time next: command\n",
+	} {
+		out, err := run(strings.Fields(args)...)
+		failIfErr(t, err)
+		mustEqual(t, out, want)
+	}
+
+	_, err := run("time", "prev")
+	mustEqual(t, err.Error(), `"time prev" not found`)
+}
+
+func TestRunner_whichAndHelpGates(t *testing.T) {
+	defer func(os, arch string) { goos, goarch = os, arch }(goos, goarch)
+	goos, goarch = "linux", "amd64"
+
+	cmds := []Command{
+		{Name: "winonly", Platforms: []string{"windows"}, ExecFunc: nopFunc},
+		{Name: "beta", Experimental: true, ExecFunc: nopFunc},
+		{Name: "tools", Subcommands: []Command{
+			{Name: "off", EnabledIf: func() bool { return false }, ExecFunc: nopFunc},
+		}},
+	}
+	run := func(args ...string) error {
+		r := RunnerOf(cmds, Config{
+			AppName: "myapp",
+			Args:    append([]string{"./myapp"}, args...),
+			Output:  io.Discard,
+			Which:   true,
+		})
+		return r.Run()
+	}
+
+	for args, want := range map[string]string{
+		"winonly":   `command "winonly" is not supported on linux/amd64`,
+		"beta":      `command "beta" is experimental, enable with MYAPP_EXPERIMENTAL=1`,
+		"tools off": `command "tools off" is not enabled`,
+	} {
+		for _, builtin := range []string{"which", "help"} {
+			err := run(append([]string{builtin}, strings.Fields(args)...)...)
+			failIfOk(t, err)
+			mustEqual(t, err.Error(), want)
+		}
+	}
+}
+
+func TestRunner_docsFS(t *testing.T) {
+	docs := fstest.MapFS{
+		"deploy.md": {Data: []byte("Deploys the app.\n\nExample:\n    myapp deploy prod\n")},
+		"auth.md":   {Data: []byte("Use a token.\n")},
+	}
+	cmds := []Command{
+		{Name: "deploy", Description: "deploys the app", DescriptionFile: "deploy.md", ExecFunc: nopFunc},
+		{Name: "broken", DescriptionFile: "missing.md", ExecFunc: nopFunc},
+	}
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		r := RunnerOf(cmds, Config{
+			AppName:    "myapp",
+			Args:       append([]string{"./myapp"}, args...),
+			Output:     buf,
+			DocsFS:     docs,
+			HelpTopics: []HelpTopic{{Name: "auth", Title: "Authentication", ContentFile: "auth.md"}},
+		})
+		err := r.Run()
+		return buf.String(), err
+	}
+
+	out, err := run("help", "deploy")
+	failIfErr(t, err)
+	mustEqual(t, out, "Deploys the app.\n\nExample:\n    myapp deploy prod\n\nUsage:\n\n    myapp deploy [arguments...]\n\n")
+
+	out, err = run("help")
+	failIfErr(t, err)
+	if !strings.Contains(out, "    deploy            deploys the app\n") {
+		t.Fatal(out)
+	}
+
+	out, err = run("help", "auth")
+	failIfErr(t, err)
+	mustEqual(t, out, "Authentication\n\nUse a token.\n\n")
+
+	_, err = run("help", "broken")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+
+	r := RunnerOf([]Command{{Name: "deploy", DescriptionFile: "deploy.md", ExecFunc: nopFunc}}, Config{Args: []string{"./myapp", "deploy"}})
+	mustEqual(t, r.Run().Error(), `command "deploy" has DescriptionFile but Config.DocsFS is nil`)
+}
+
+func TestRunner_helpFlagForParent(t *testing.T) {
+	cmds := []Command{
+		{Name: "test", Description: "test commands", Subcommands: []Command{
+			{Name: "foo", Description: "foo commands", Subcommands: []Command{
+				{Name: "bar", Description: "runs bar", ExecFunc: nopFunc},
+			}},
+		}},
+	}
+	run := func(args ...string) string {
+		buf := &bytes.Buffer{}
+		r := RunnerOf(cmds, Config{AppName: "myapp", Args: append([]string{"./myapp"}, args...), Output: buf})
+		failIfErr(t, r.Run())
+		return buf.String()
+	}
+
+	want := run("help", "test", "foo")
+	if !strings.Contains(want, "    bar") {
+		t.Fatal(want)
+	}
+	mustEqual(t, run("test", "foo", "--help"), want)
+	mustEqual(t, run("test", "foo", "-h", "bar"), want)
+
+	r := RunnerOf(cmds, Config{Args: []string{"./myapp", "test"}})
+	path, cmd, rest, err := r.Resolve([]string{"test", "-help"})
+	failIfErr(t, err)
+	mustEqual(t, path, []string{"test"})
+	mustEqual(t, cmd.Name, "test")
+	mustEqual(t, rest, []string{"-help"})
+}
+
+func TestRunner_lazySubcommands(t *testing.T) {
+	var loads int
+	var got string
+	newCmds := func() []Command {
+		loads = 0
+		return []Command{
+			{Name: "now", ExecFunc: nopFunc},
+			{
+				Name: "plugin",
+				SubcommandsFunc: func() []Command {
+					loads++
+					return []Command{
+						{Name: "lint", Description: "lints", ExecFunc: func(ctx context.Context, args []string) error {
+							got = "lint"
+							return nil
+						}},
+					}
+				},
+			},
+		}
+	}
+
+	cmds := newCmds()
+	r := RunnerOf(cmds, Config{Args: []string{"./myapp", "now"}, Output: io.Discard})
+	failIfErr(t, r.Run())
+	mustEqual(t, loads, 0)
+
+	cmds = newCmds()
+	r = RunnerOf(cmds, Config{Args: []string{"./myapp", "plugin", "lint"}, Output: io.Discard})
+	failIfErr(t, r.Run())
+	mustEqual(t, got, "lint")
+	mustEqual(t, loads, 1)
+
+	r.Snapshot(SnapshotOptions{})
+	mustEqual(t, loads, 1)
+
+	buf := &bytes.Buffer{}
+	cmds = newCmds()
+	r = RunnerOf(cmds, Config{Args: []string{"./myapp", "help"}, Output: buf})
+	failIfErr(t, r.Run())
+	mustEqual(t, loads, 1)
+	if !strings.Contains(buf.String(), "plugin lint") {
+		t.Fatal(buf.String())
+	}
+
+	bad := []Command{{Name: "plugin", SubcommandsFunc: func() []Command {
+		return []Command{{Name: "help", ExecFunc: nopFunc}}
+	}}}
+	r = RunnerOf(bad, Config{Args: []string{"./myapp", "plugin", "help"}, Output: io.Discard})
+	var errReserved ErrReservedName
+	if !errors.As(r.Run(), &errReserved) {
+		t.Fatal("lazy subcommands must be validated")
+	}
+	mustEqual(t, errReserved.Path, "plugin help")
+}
+
+func TestRunner_helpAll(t *testing.T) {
+	fset := flag.NewFlagSet("now", flag.ContinueOnError)
+	fset.String("at", time.Now().Format(time.Kitchen), "time to print")
+	cmds := []Command{{Name: "now", ExecFunc: nopFunc, FlagSet: &copyCmd{fset: fset}}}
+
+	run := func(args ...string) string {
+		buf := &bytes.Buffer{}
+		r := RunnerOf(cmds, Config{
+			Args:      append([]string{"./myapp", "help"}, args...),
+			Output:    buf,
+			Providers: []CommandProvider{&testProvider{remote: []string{"deploy"}}},
+		})
+		failIfErr(t, r.Run())
+		return buf.String()
+	}
+
+	plain := run("--all", "--plain")
+	if strings.Contains(plain, "deploy") || strings.Contains(plain, "default") {
+		t.Fatal(plain)
+	}
+	if !strings.Contains(plain, "now\n    flag: -at string: time to print\n") {
+		t.Fatal(plain)
+	}
+
+	all := run("--all")
+	if !strings.Contains(all, "deploy\n") || !strings.Contains(all, "    flag: -at string (default ") {
+		t.Fatal(all)
+	}
+}
+
+func TestSnapshotOptions(t *testing.T) {
+	cmds := []Command{
+		{Name: "now", Alias: "n", ExecFunc: nopFunc},
+		{Name: "secret", IsHidden: true, Subcommands: []Command{{Name: "key", ExecFunc: nopFunc}}},
+		{Name: "time", Subcommands: []Command{
+			{Name: "next", Alias: "nx", ExecFunc: nopFunc},
+		}},
+	}
+	r := RunnerOf(cmds, Config{Args: []string{"./myapp", "help"}, Output: io.Discard})
+	failIfErr(t, r.Run())
+
+	want := "help\n    description: shows help message\n" +
+		"now\n" +
+		"time\n" +
+		"version\n    description: shows version of the application\n"
+	mustEqual(t, r.Snapshot(SnapshotOptions{NoAliases: true, MaxDepth: 1}), want)
+
+	got := r.Snapshot(SnapshotOptions{
+		Hidden: true,
+		FrontMatter: func(path []string, cmd *Command) []string {
+			return []string{"depth: " + strconv.Itoa(len(path))}
+		},
+	})
+	for _, s := range []string{
+		"now\n    depth: 1\n    alias: n\n",
+		"secret key\n    depth: 2\n",
+		"time next\n    depth: 2\n    alias: nx\n",
+	} {
+		if !strings.Contains(got, s) {
+			t.Fatal(got)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	r = RunnerOf(cmds, Config{Args: []string{"./myapp", "help", "--all", "--plain", "--depth=1", "--no-aliases"}, Output: buf})
+	failIfErr(t, r.Run())
+	mustEqual(t, buf.String(), want)
+
+	r = RunnerOf(cmds, Config{Args: []string{"./myapp", "help", "--all", "--depth=0"}, Output: io.Discard})
+	if err := r.Run(); err == nil || err.Error() != `invalid --depth value "0"` {
+		t.Fatal(err)
+	}
+}
+
+func TestRunner_helpTruncatedToTerminal(t *testing.T) {
+	defer func(f func(w io.Writer) int) { terminalWidth = f }(terminalWidth)
+
+	const prefix = "    foo               "
+	cmds := []Command{{Name: "foo", Description: "does a lot of different things", ExecFunc: nopFunc}}
+
+	testCases := []struct {
+		width int
+		args  []string
+		wide  bool
+		want  string
+	}{
+		{width: 40, args: []string{"help"}, want: "does a lot of dif…"},
+		{width: 40, args: []string{"--wide", "help"}, want: "does a lot of different things"},
+		{width: 40, args: []string{"help"}, wide: true, want: "does a lot of different things"},
+		{width: 50, args: []string{"help"}, want: "does a lot of different thi…"},
+		{width: 52, args: []string{"help"}, want: "does a lot of different things"},
+		{width: 30, args: []string{"help"}, want: "does a lot of different things"}, // too narrow to truncate.
+		{width: 0, args: []string{"help"}, want: "does a lot of different things"},  // not a terminal.
+	}
+
+	for _, tc := range testCases {
+		width := tc.width
+		terminalWidth = func(w io.Writer) int { return width }
+
+		buf := &bytes.Buffer{}
+		r := RunnerOf(cmds, Config{
+			Args:   append([]string{"./myapp"}, tc.args...),
+			Output: buf,
+			Wide:   tc.wide,
+		})
+		failIfErr(t, r.Run())
+
+		if !strings.Contains(buf.String(), prefix+tc.want+"\n") {
+			t.Fatalf("width %d, args %q:\n%s", tc.width, tc.args, buf.String())
+		}
+	}
+}
+
+func TestRunner_RunContext(t *testing.T) {
+	type key struct{}
+	var got []interface{}
+	cmds := []Command{{
+		Name: "foo",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			got = append(got, ctx.Value(key{}))
+			return ctx.Err()
+		},
+	}}
+	r := RunnerOf(cmds, Config{
+		Args:    []string{"./someapp", "--offline", "foo"},
+		Output:  io.Discard,
+		Context: context.WithValue(context.Background(), key{}, "config"),
+	})
+
+	failIfErr(t, r.Run())
+	failIfErr(t, r.RunContext(context.WithValue(context.Background(), key{}, "run")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.RunContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+	mustEqual(t, got, []interface{}{"config", "run", nil})
+	mustEqual(t, IsOffline(r.ctx), true)
+}
+
+func TestRunner_builtinDescriptions(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := RunnerOf([]Command{{Name: "foo", Description: "foo", ExecFunc: nopFunc}}, Config{
+		AppName:            "myapp",
+		Args:               []string{"./myapp", "help"},
+		Output:             buf,
+		HelpDescription:    "zeigt Hilfe an",
+		VersionDescription: "zeigt Version an",
+	})
+	failIfErr(t, r.Run())
+
+	if !strings.Contains(buf.String(), "    help              zeigt Hilfe an\n") ||
+		!strings.Contains(buf.String(), "    version           zeigt Version an\n") {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestRunner_builtinOverrides(t *testing.T) {
+	fset := flag.NewFlagSet("version", flag.ContinueOnError)
+	fset.Bool("short", false, "print only the version")
+
+	cmds := []Command{{Name: "foo", Description: "foo", ExecFunc: nopFunc}}
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		r := RunnerOf(cmds, Config{
+			AppName:        "myapp",
+			Version:        "v1.0.0",
+			Args:           append([]string{"./myapp"}, args...),
+			Output:         buf,
+			HelpCommand:    &Command{Alias: "-h", Description: "Hilfe"},
+			VersionCommand: &Command{FlagSet: &copyCmd{fset: fset}},
+		})
+		err := r.Run()
+		return buf.String(), err
+	}
+
+	out, err := run("-h")
+	failIfErr(t, err)
+	if !strings.Contains(out, "    help (-h)           Hilfe\n") {
+		t.Fatal(out)
+	}
+
+	out, err = run("help", "version")
+	failIfErr(t, err)
+	if !strings.Contains(out, "shows version of the application\n") || !strings.Contains(out, "-short") {
+		t.Fatal(out)
+	}
+
+	out, err = run("version")
+	failIfErr(t, err)
+	mustEqual(t, out, "myapp version: v1.0.0\n\n")
+
+	var reservedErr ErrReservedName
+	r := RunnerOf([]Command{{Name: "hist", Alias: "-h", ExecFunc: nopFunc}}, Config{
+		Args:        []string{"./myapp", "hist"},
+		HelpCommand: &Command{Alias: "-h"},
+	})
+	if err := r.Run(); !errors.As(err, &reservedErr) || reservedErr.Name != "-h" || !reservedErr.IsAlias {
+		t.Fatal(err)
+	}
+
+	r = RunnerOf(cmds, Config{
+		Args:        []string{"./myapp", "foo"},
+		HelpCommand: &Command{Name: "hilfe"},
+	})
+	mustEqual(t, r.Run().Error(), `help command override cannot change the name to "hilfe"`)
+}
+
+func TestRunner_BuiltinAliases(t *testing.T) {
+	cmds := []Command{{Name: "foo", ExecFunc: nopFunc}}
+	run := func(cmds []Command, cfg Config, args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		cfg.AppName = "myapp"
+		cfg.Version = "v1.0.0"
+		cfg.Args = append([]string{"./myapp"}, args...)
+		cfg.Output = buf
+		cfg.BuiltinAliases = true
+		err := RunnerOf(cmds, cfg).Run()
+		return buf.String(), err
+	}
+
+	out, err := run(cmds, Config{}, "v")
+	failIfErr(t, err)
+	mustEqual(t, out, "myapp version: v1.0.0\n\n")
+
+	out, err = run(cmds, Config{}, "h")
+	failIfErr(t, err)
+	if !strings.Contains(out, "    help (h)              shows help message\n") {
+		t.Fatal(out)
+	}
+
+	help := &Command{Alias: "hlp"}
+	out, err = run(cmds, Config{HelpCommand: help}, "hlp")
+	failIfErr(t, err)
+	if !strings.Contains(out, "    help (hlp)            shows help message\n") {
+		t.Fatal(out)
+	}
+	mustEqual(t, help.Alias, "hlp")
+	_, err = run(cmds, Config{HelpCommand: help}, "h")
+	failIfOk(t, err)
+
+	var reservedErr ErrReservedName
+	_, err = run([]Command{{Name: "view", Alias: "v", ExecFunc: nopFunc}}, Config{}, "view")
+	if !errors.As(err, &reservedErr) || reservedErr.Name != "v" {
+		t.Fatal(err)
+	}
+	_, err = run([]Command{{Name: "h", ExecFunc: nopFunc}}, Config{}, "h")
+	if !errors.As(err, &reservedErr) || reservedErr.Name != "h" {
+		t.Fatal(err)
+	}
+}
+
+func TestRunner_builtinsHonorContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, name := range []string{"help", "version", "__resolve"} {
+		buf := &bytes.Buffer{}
+		r := RunnerOf([]Command{{Name: "foo", ExecFunc: nopFunc}}, Config{
+			Args:    []string{"./myapp", name},
+			Output:  buf,
+			Context: ctx,
+		})
+		if err := r.Run(); !errors.Is(err, context.Canceled) {
+			t.Fatalf("%s: got %v", name, err)
+		}
+		mustEqual(t, buf.String(), "")
+	}
+}
+
+func TestRunner_bench(t *testing.T) {
+	var runs int
+	cmds := []Command{{
+		Name: "foo",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			runs++
+			return nil
+		},
+	}}
+	defer func(f func() (string, error)) { userCacheDir = f }(userCacheDir)
+	dir := t.TempDir()
+	userCacheDir = func() (string, error) { return dir, nil }
+
+	errBuf, eventBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	r := RunnerOf(cmds, Config{
+		Args:        []string{"./someapp", "--bench", "3", "foo", "bar"},
+		Output:      io.Discard,
+		ErrOutput:   errBuf,
+		EventOutput: eventBuf,
+		BenchFlag:   true,
+		History:     true,
+	})
+	failIfErr(t, r.Run())
+	mustEqual(t, runs, 3)
+
+	lines := strings.Split(errBuf.String(), "\n")
+	mustEqual(t, len(lines), 4)
+	mustEqual(t, lines[0], `bench: "foo bar", 3 runs`)
+	if !strings.HasPrefix(lines[1], "    time:   min ") || !strings.HasPrefix(lines[2], "    allocs: ") {
+		t.Fatal(errBuf.String())
+	}
+	mustEqual(t, eventBuf.String(), "")
+	if _, err := os.Stat(filepath.Join(dir, "someapp", "history")); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	r = RunnerOf(cmds, Config{
+		Args:      []string{"./someapp", "--bench=0", "foo"},
+		Output:    io.Discard,
+		BenchFlag: true,
+	})
+	if err := r.Run(); err == nil || err.Error() != `invalid --bench value "0"` {
+		t.Fatal(err)
+	}
+
+	r = RunnerOf(cmds, Config{
+		Args:   []string{"./someapp", "--bench", "3", "foo"},
+		Output: io.Discard,
+		Usage:  nopUsage,
+	})
+	if err := r.Run(); err == nil || !strings.Contains(err.Error(), `no such command "--bench"`) {
+		t.Fatal(err)
+	}
+}
+
+func TestIsValidName(t *testing.T) {
+	mustEqual(t, IsValidName("time-zone_v1.2:list"), true)
+	mustEqual(t, IsValidName(""), false)
+	mustEqual(t, IsValidName("time zone"), false)
+	mustEqual(t, IsValidName("zeit-ä"), false)
+}
+
+func TestRunner_ValidateName(t *testing.T) {
+	r := RunnerOf([]Command{{Name: "foo", ExecFunc: nopFunc}}, Config{
+		Args:               []string{"./someapp", "foo"},
+		BannedCommandNames: []string{"exit"},
+		AllowUnicodeNames:  true,
+	})
+	failIfErr(t, r.Run())
+
+	failIfErr(t, r.ValidateName("bar"))
+	failIfErr(t, r.ValidateName("zeit-ä"))
+
+	var reservedErr ErrReservedName
+	if err := r.ValidateName("help"); !errors.As(err, &reservedErr) {
+		t.Fatal(err)
+	}
+
+	var invalidErr ErrInvalidName
+	if err := r.ValidateName("exit"); !errors.As(err, &invalidErr) || invalidErr.Rule != "is banned" {
+		t.Fatal(err)
+	}
+	mustEqual(t, r.ValidateName("a b").Error(), `command "a b" must contains only letters, digits, -, _, : and .`)
+}
+
+func TestExitWithHint(t *testing.T) {
+	var gotStatus int
+	doExitOld := func(code int) {
+		gotStatus = code
+	}
+	defer func() { doExit = doExitOld }()
+	doExitOld, doExit = doExit, doExitOld
+
+	buf := &bytes.Buffer{}
+	r := RunnerOf([]Command{{Name: "foo", ExecFunc: nopFunc}}, Config{
+		AppName:   "myapp",
+		Args:      []string{"./myapp", "foo"},
+		ErrOutput: buf,
+	})
+
+	err := WithHint(fmt.Errorf("get token: %w", ErrCode(3)), `try running "myapp auth login"`)
+	err = fmt.Errorf("sync: %w", err)
+	mustEqual(t, ErrorHint(err), `try running "myapp auth login"`)
+
+	r.Exit(err)
+	mustEqual(t, gotStatus, 3)
+	mustEqual(t, buf.String(), "myapp: sync: get token: code 3\nhint: try running \"myapp auth login\"\n")
+
+	mustEqual(t, WithHint(nil, "hint"), nil)
+	mustEqual(t, ErrorHint(errors.New("no hint")), "")
+}
+
+func TestExitCodes(t *testing.T) {
+	errNotFound := errors.New("not found")
+	errConflict := errors.New("conflict")
+
+	var gotStatus int
+	doExitOld := func(code int) {
+		gotStatus = code
+	}
+	defer func() { doExit = doExitOld }()
+	doExitOld, doExit = doExit, doExitOld
+
+	r := RunnerOf([]Command{{Name: "foo", ExecFunc: nopFunc}}, Config{
+		Args:      []string{"./someapp", "foo"},
+		Output:    io.Discard,
+		ErrOutput: io.Discard,
+		ExitCodes: map[error]int{
+			errNotFound: 4,
+			errConflict: 9,
+		},
+	})
+
+	r.Exit(fmt.Errorf("get user: %w", errNotFound))
+	mustEqual(t, gotStatus, 4)
+
+	r.Exit(errConflict)
+	mustEqual(t, gotStatus, 9)
+
+	r.Exit(fmt.Errorf("%w: %v", ErrCode(3), errConflict))
+	mustEqual(t, gotStatus, 3)
+
+	r.Exit(errors.New("other"))
+	mustEqual(t, gotStatus, 1)
+}
+
+func TestExitCodes_manyMatches(t *testing.T) {
+	errNotFound := errors.New("not found")
+	errConflict := fmt.Errorf("conflict: %w", errNotFound)
+
+	var gotStatus int
+	doExitOld := func(code int) {
+		gotStatus = code
+	}
+	defer func() { doExit = doExitOld }()
+	doExitOld, doExit = doExit, doExitOld
+
+	r := RunnerOf([]Command{{Name: "foo", ExecFunc: nopFunc}}, Config{
+		Args:      []string{"./someapp", "foo"},
+		Output:    io.Discard,
+		ErrOutput: io.Discard,
+		ExitCodes: map[error]int{
+			errNotFound: 4,
+			errConflict: 9,
+		},
+	})
+
+	// map iteration order is random, repeat to catch it.
+	for i := 0; i < 20; i++ {
+		r.Exit(fmt.Errorf("update user: %w", errConflict))
+		mustEqual(t, gotStatus, 9)
+
+		r.Exit(fmt.Errorf("update user: %w", multiIsError{errNotFound, errConflict}))
+		mustEqual(t, gotStatus, 4)
+	}
+}
+
+// multiIsError matches all the errors on the same level.
+type multiIsError []error
+
+func (e multiIsError) Error() string { return "multi" }
+
+func (e multiIsError) Is(target error) bool {
+	for _, err := range e {
+		if err == target {
+			return true
+		}
+	}
+	return false
+}
+
 func failIfOk(tb testing.TB, err error) {
 	tb.Helper()
 	if err == nil {
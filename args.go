@@ -0,0 +1,110 @@
+package acmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseKV collects `key=value` pairs from args, ex: `myapp set replicas=3 image=foo`.
+// Args without `=` and flags (starting with `-`) are returned in rest in the same order.
+// Empty keys and duplicate keys are reported as errors. Flag values must be passed
+// as `--flag=value` or flags must be parsed before (ex: pass FlagSet.Args()):
+// a pair right after a flag without a value is reported as an error, as it can be the flag value.
+// See also Command.KVArgs.
+func ParseKV(args []string) (kv map[string]string, rest []string, err error) {
+	kv = make(map[string]string)
+	var lastFlag string
+	for i, arg := range args {
+		idx := strings.IndexByte(arg, '=')
+		if idx == -1 || strings.HasPrefix(arg, "-") {
+			rest = append(rest, arg)
+			lastFlag = ""
+			if idx == -1 && len(arg) > 1 && arg[0] == '-' && arg != "--" {
+				lastFlag = arg
+			}
+			continue
+		}
+
+		key, value := arg[:idx], arg[idx+1:]
+		switch _, ok := kv[key]; {
+		case key == "":
+			return nil, nil, fmt.Errorf("malformed pair %q at arg %d: key cannot be empty", arg, i+1)
+		case ok:
+			return nil, nil, fmt.Errorf("malformed pair %q at arg %d: duplicate key %q", arg, i+1, key)
+		case lastFlag != "":
+			return nil, nil, fmt.Errorf("malformed pair %q at arg %d: can be a value of flag %q, use %s=%s",
+				arg, i+1, lastFlag, lastFlag, arg)
+		}
+		kv[key] = value
+	}
+	return kv, rest, nil
+}
+
+// SplitArgs splits s into args like a POSIX shell does (without expansions).
+// Single quotes keep everything literally, double quotes allow \" \\ \$ and \` escapes,
+// backslash outside of quotes escapes the next char.
+// Ex: `--msg 'hello world' -v` is split into "--msg", "hello world" and "-v".
+func SplitArgs(s string) ([]string, error) {
+	var (
+		args    []string
+		arg     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, c := range s {
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("\"\\$`", c) {
+				arg.WriteRune('\\') // not an escape inside of double quotes
+			}
+			arg.WriteRune(c)
+			escaped = false
+
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(c)
+			}
+
+		case quote == '"':
+			switch c {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				arg.WriteRune(c)
+			}
+
+		case c == '\\':
+			escaped, inArg = true, true
+
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+
+	switch {
+	case quote != 0:
+		return nil, fmt.Errorf("unterminated %c quote in %q", quote, s)
+	case escaped:
+		return nil, fmt.Errorf("trailing backslash in %q", s)
+	case inArg:
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
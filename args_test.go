@@ -0,0 +1,89 @@
+package acmd
+
+import "testing"
+
+func TestParseKV(t *testing.T) {
+	testCases := []struct {
+		args       []string
+		wantKV     map[string]string
+		wantRest   []string
+		wantErrStr string
+	}{
+		{
+			args:   []string{},
+			wantKV: map[string]string{},
+		},
+		{
+			args:     []string{"replicas=3", "image=foo", "deploy"},
+			wantKV:   map[string]string{"replicas": "3", "image": "foo"},
+			wantRest: []string{"deploy"},
+		},
+		{
+			args:     []string{"--env=prod", "a=", "b=c=d", "-v"},
+			wantKV:   map[string]string{"a": "", "b": "c=d"},
+			wantRest: []string{"--env=prod", "-v"},
+		},
+		{
+			args:       []string{"a=1", "=2"},
+			wantErrStr: `malformed pair "=2" at arg 2: key cannot be empty`,
+		},
+		{
+			args:       []string{"a=1", "b=2", "a=3"},
+			wantErrStr: `malformed pair "a=3" at arg 3: duplicate key "a"`,
+		},
+		{
+			args:       []string{"--label", "a=b"},
+			wantErrStr: `malformed pair "a=b" at arg 2: can be a value of flag "--label", use --label=a=b`,
+		},
+		{
+			args:     []string{"--label=a=b", "--", "c=d"},
+			wantKV:   map[string]string{"c": "d"},
+			wantRest: []string{"--label=a=b", "--"},
+		},
+	}
+
+	for _, tc := range testCases {
+		kv, rest, err := ParseKV(tc.args)
+		if tc.wantErrStr != "" {
+			failIfOk(t, err)
+			mustEqual(t, err.Error(), tc.wantErrStr)
+			continue
+		}
+		failIfErr(t, err)
+		mustEqual(t, kv, tc.wantKV)
+		mustEqual(t, rest, tc.wantRest)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	testCases := []struct {
+		s          string
+		want       []string
+		wantErrStr string
+	}{
+		{``, nil, ``},
+		{`   `, nil, ``},
+		{`a b  c`, []string{"a", "b", "c"}, ``},
+		{`--msg 'hello world' -v`, []string{"--msg", "hello world", "-v"}, ``},
+		{`--msg="hello world"`, []string{"--msg=hello world"}, ``},
+		{`"" ''`, []string{"", ""}, ``},
+		{`a\ b c`, []string{"a b", "c"}, ``},
+		{`"say \"hi\"" 'it''s'`, []string{`say "hi"`, "its"}, ``},
+		{`"C:\dir\file"`, []string{`C:\dir\file`}, ``},
+		{`'a\b'`, []string{`a\b`}, ``},
+		{`"oops`, nil, `unterminated " quote in "\"oops"`},
+		{`it's`, nil, `unterminated ' quote in "it's"`},
+		{`end\`, nil, `trailing backslash in "end\\"`},
+	}
+
+	for _, tc := range testCases {
+		args, err := SplitArgs(tc.s)
+		if tc.wantErrStr != "" {
+			failIfOk(t, err)
+			mustEqual(t, err.Error(), tc.wantErrStr)
+			continue
+		}
+		failIfErr(t, err)
+		mustEqual(t, args, tc.want)
+	}
+}
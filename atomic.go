@@ -0,0 +1,70 @@
+package acmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic writes data to the file like os.WriteFile but atomically:
+// data is written to a temporary file in the same dir, synced and renamed to name.
+// So the file is never left half-written, ex: when the app is interrupted.
+func WriteFileAtomic(name string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(name)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op after the successful rename.
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, name); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// WriteFileBackup is like WriteFileAtomic but the current content of the file
+// (if it exists) is kept in the file with .bak suffix.
+func WriteFileBackup(name string, data []byte, perm os.FileMode) error {
+	old, err := os.ReadFile(name)
+	switch {
+	case err == nil:
+		if err := WriteFileAtomic(name+".bak", old, perm); err != nil {
+			return err
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	return WriteFileAtomic(name, data, perm)
+}
+
+// syncDir to persist the rename, not supported on all platforms, so errors on open are ignored.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil && goos != "windows" {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package acmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "config.json")
+
+	failIfErr(t, WriteFileAtomic(name, []byte("v1"), 0o600))
+	failIfErr(t, WriteFileAtomic(name, []byte("v2"), 0o600))
+
+	data, err := os.ReadFile(name)
+	failIfErr(t, err)
+	mustEqual(t, string(data), "v2")
+
+	stat, err := os.Stat(name)
+	failIfErr(t, err)
+	if goos != "windows" {
+		mustEqual(t, stat.Mode().Perm(), os.FileMode(0o600))
+	}
+
+	entries, err := os.ReadDir(dir)
+	failIfErr(t, err)
+	mustEqual(t, len(entries), 1)
+
+	err = WriteFileAtomic(filepath.Join(dir, "missing", "file"), []byte("v1"), 0o600)
+	failIfOk(t, err)
+}
+
+func TestWriteFileBackup(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+
+	failIfErr(t, WriteFileBackup(name, []byte("v1"), 0o600))
+	_, err := os.Stat(name + ".bak")
+	mustEqual(t, os.IsNotExist(err), true)
+
+	failIfErr(t, WriteFileBackup(name, []byte("v2"), 0o600))
+
+	data, err := os.ReadFile(name)
+	failIfErr(t, err)
+	mustEqual(t, string(data), "v2")
+
+	data, err = os.ReadFile(name + ".bak")
+	failIfErr(t, err)
+	mustEqual(t, string(data), "v1")
+}
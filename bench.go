@@ -0,0 +1,48 @@
+package acmd
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// runBench runs the command for args r.bench times and reports
+// wall time and allocations of the runs to ErrOutput.
+// Stops on the first failed run. History and events are not recorded, see Config.BenchFlag.
+func (r *Runner) runBench(ctx context.Context, args []string) error {
+	var minDur, maxDur, total time.Duration
+	var mallocs, bytes uint64
+	var before, after runtime.MemStats
+
+	for i := 0; i < r.bench; i++ {
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+
+		err := r.runArgs(ctx, args)
+
+		dur := time.Since(start)
+		runtime.ReadMemStats(&after)
+		if err != nil {
+			return err
+		}
+
+		total += dur
+		if i == 0 || dur < minDur {
+			minDur = dur
+		}
+		if dur > maxDur {
+			maxDur = dur
+		}
+		mallocs += after.Mallocs - before.Mallocs
+		bytes += after.TotalAlloc - before.TotalAlloc
+	}
+
+	n := uint64(r.bench)
+	w := r.cfg.ErrOutput
+	fmt.Fprintf(w, "bench: %q, %d runs\n", strings.Join(args, " "), r.bench)
+	fmt.Fprintf(w, "    time:   min %v, avg %v, max %v\n", minDur, total/time.Duration(r.bench), maxDur)
+	fmt.Fprintf(w, "    allocs: %d allocs/run, %d B/run\n", mallocs/n, bytes/n)
+	return nil
+}
@@ -0,0 +1,232 @@
+package acmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// checkpointEntry is a single checkpoint file.
+type checkpointEntry struct {
+	Time  time.Time       `json:"time"`
+	State json.RawMessage `json:"state"`
+}
+
+// Checkpoint saves the state (encoded as JSON) of the command run with ctx under the name,
+// so the command can continue from it with Restore after an interruption.
+// Checkpoints are kept in the user cache dir, ones saved or restored by the run are removed
+// when the command succeeds. Checkpoints of the command are locked for the whole run,
+// ErrAlreadyRunning is returned if another instance of the command holds them.
+// Name must not contain path separators.
+func Checkpoint(ctx context.Context, name string, state interface{}) error {
+	inv := invocationFrom(ctx)
+	if inv == nil {
+		return errors.New("checkpoint: context is not from the Runner")
+	}
+	if err := checkCheckpointName(name); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("checkpoint %q: %w", name, err)
+	}
+	data, err = json.Marshal(checkpointEntry{Time: time.Now(), State: data})
+	if err != nil {
+		return fmt.Errorf("checkpoint %q: %w", name, err)
+	}
+
+	dir, err := inv.checkpointDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if err := inv.lockCheckpoints(dir); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name+".json")
+	if err := WriteFileAtomic(path, data, 0o600); err != nil {
+		return err
+	}
+	inv.addCheckpoint(path)
+	return nil
+}
+
+// Restore loads the state saved by Checkpoint under the name into state.
+// Returns false if the app is run without --resume flag or there is no such checkpoint
+// or it's older than Config.CheckpointTTL.
+func Restore(ctx context.Context, name string, state interface{}) (bool, error) {
+	inv := invocationFrom(ctx)
+	if inv == nil || !inv.runner.resume {
+		return false, nil
+	}
+	if err := checkCheckpointName(name); err != nil {
+		return false, err
+	}
+
+	dir, err := inv.checkpointDir()
+	if err != nil {
+		return false, err
+	}
+	path := filepath.Join(dir, name+".json")
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if err := inv.lockCheckpoints(dir); err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	inv.addCheckpoint(path)
+
+	var entry checkpointEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return false, fmt.Errorf("restore %q: %w", name, err)
+	}
+	if time.Since(entry.Time) > inv.runner.cfg.CheckpointTTL {
+		return false, nil
+	}
+	if err := json.Unmarshal(entry.State, state); err != nil {
+		return false, fmt.Errorf("restore %q: %w", name, err)
+	}
+	return true, nil
+}
+
+// checkpointDir for the command, ex: `~/.cache/myapp/checkpoints/db+migrate`.
+func (inv *invocation) checkpointDir() (string, error) {
+	dir, err := appDir(userCacheDir, inv.runner.cfg.AppName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "checkpoints", checkpointDirName(inv.path)), nil
+}
+
+// checkpointDirName for the command path, each name is escaped (see escapeFileName)
+// and joined with "+", which is always escaped in the names, so different paths don't collide.
+func checkpointDirName(path []string) string {
+	names := make([]string, len(path))
+	for i, name := range path {
+		names[i] = escapeFileName(name)
+	}
+	return strings.Join(names, "+")
+}
+
+// escapeFileName makes name safe for a file name on any OS, ex: `a:b` is `a%3Ab`.
+// Only letters, digits, - and _ are kept, dots too except a trailing one (not allowed on Windows).
+// Reserved device names on Windows (CON, NUL, COM1, etc) are escaped too.
+func escapeFileName(name string) string {
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9'):
+		case c == '-' || c == '_':
+		case c == '.' && i != len(name)-1:
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+			continue
+		}
+		if i == 0 && isReservedFileName(name) {
+			fmt.Fprintf(&sb, "%%%02X", c)
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// isReservedFileName reports whether name is a device name on Windows, extension doesn't matter.
+func isReservedFileName(name string) bool {
+	base := strings.ToUpper(strings.SplitN(name, ".", 2)[0])
+	switch base {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	}
+	return len(base) == 4 && (strings.HasPrefix(base, "COM") || strings.HasPrefix(base, "LPT")) &&
+		'1' <= base[3] && base[3] <= '9'
+}
+
+// checkCheckpointName doesn't allow names escaping the checkpoint dir.
+func checkCheckpointName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("checkpoint %q: invalid name", name)
+	}
+	return nil
+}
+
+// lockCheckpoints dir till the end of the run, so concurrent instances of the command
+// don't overwrite or remove checkpoints of each other. Must be called with inv.mu held.
+func (inv *invocation) lockCheckpoints(dir string) error {
+	if inv.checkpointLock != nil {
+		return nil
+	}
+
+	f, ok, err := openLocked(filepath.Join(dir, ".lock"), 0o600)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("checkpoints of %q: %w", strings.Join(inv.path, " "), ErrAlreadyRunning)
+	}
+	if err := writePID(f); err != nil {
+		releaseLocked(f)
+		return err
+	}
+	inv.checkpointLock = f
+	return nil
+}
+
+// addCheckpoint to the ones removed after the successful run. Must be called with inv.mu held.
+func (inv *invocation) addCheckpoint(path string) {
+	for _, p := range inv.checkpoints {
+		if p == path {
+			return
+		}
+	}
+	inv.checkpoints = append(inv.checkpoints, path)
+}
+
+// removeCheckpoints saved or restored by the run, done after the successful run.
+func (inv *invocation) removeCheckpoints() {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	for _, path := range inv.checkpoints {
+		os.Remove(path)
+	}
+	inv.checkpoints = nil
+}
+
+// unlockCheckpoints locked by the run, if any. The dir is removed if no checkpoints are left.
+func (inv *invocation) unlockCheckpoints() {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if inv.checkpointLock == nil {
+		return
+	}
+	dir := filepath.Dir(inv.checkpointLock.Name())
+	releaseLocked(inv.checkpointLock)
+	inv.checkpointLock = nil
+	os.Remove(dir)
+}
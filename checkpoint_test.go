@@ -0,0 +1,150 @@
+package acmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	defer func(f func() (string, error)) { userCacheDir = f }(userCacheDir)
+	dir := t.TempDir()
+	userCacheDir = func() (string, error) { return dir, nil }
+
+	type migration struct {
+		Applied int `json:"applied"`
+	}
+	errInterrupted := errors.New("interrupted")
+
+	var started []int
+	cmds := []Command{{
+		Name: "db",
+		Subcommands: []Command{{
+			Name: "migrate",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				var state migration
+				if _, err := Restore(ctx, "progress", &state); err != nil {
+					return err
+				}
+				started = append(started, state.Applied)
+
+				for state.Applied < 3 {
+					state.Applied++
+					if err := Checkpoint(ctx, "progress", state); err != nil {
+						return err
+					}
+					if len(args) != 0 && state.Applied == 2 {
+						return errInterrupted
+					}
+				}
+				return nil
+			},
+		}},
+	}}
+
+	run := func(args ...string) error {
+		r := RunnerOf(cmds, Config{
+			AppName: "myapp",
+			Args:    append([]string{"./myapp"}, args...),
+			Output:  io.Discard,
+		})
+		return r.Run()
+	}
+
+	mustEqual(t, run("db", "migrate", "fail"), errInterrupted)
+	mustEqual(t, run("--resume", "db", "migrate"), nil)
+	mustEqual(t, started, []int{0, 2})
+
+	// removed after success, so nothing to resume.
+	_, err := os.Stat(filepath.Join(dir, "myapp", "checkpoints", "db+migrate"))
+	mustEqual(t, os.IsNotExist(err), true)
+
+	mustEqual(t, run("--resume", "db", "migrate"), nil)
+	mustEqual(t, started, []int{0, 2, 0})
+}
+
+func TestCheckpointDirName(t *testing.T) {
+	testCases := []struct {
+		path []string
+		want string
+	}{
+		{[]string{"db", "migrate"}, "db+migrate"},
+		{[]string{"db_migrate"}, "db_migrate"},
+		{[]string{"db+migrate"}, "db%2Bmigrate"},
+		{[]string{"time:zone", "v1.2"}, "time%3Azone+v1.2"},
+		{[]string{"..", "x."}, ".%2E+x%2E"},
+		{[]string{"con", "nul.txt", "com1", "console"}, "%63on+%6Eul.txt+%63om1+console"},
+		{[]string{"a/b", `c\d`, "статус"}, "a%2Fb+c%5Cd+%D1%81%D1%82%D0%B0%D1%82%D1%83%D1%81"},
+	}
+
+	for _, tc := range testCases {
+		mustEqual(t, checkpointDirName(tc.path), tc.want)
+	}
+}
+
+func TestCheckpointOutsideRunner(t *testing.T) {
+	ctx := context.Background()
+	failIfOk(t, Checkpoint(ctx, "name", 1))
+
+	ok, err := Restore(ctx, "name", new(int))
+	failIfErr(t, err)
+	mustEqual(t, ok, false)
+}
+
+func TestCheckpoint_keepsOthers(t *testing.T) {
+	defer func(f func() (string, error)) { userCacheDir = f }(userCacheDir)
+	dir := t.TempDir()
+	userCacheDir = func() (string, error) { return dir, nil }
+
+	other := filepath.Join(dir, "myapp", "checkpoints", "upload", "other.json")
+	failIfErr(t, os.MkdirAll(filepath.Dir(other), 0o700))
+	failIfErr(t, os.WriteFile(other, []byte(`{}`), 0o600))
+
+	var checkpointErr, restoreErr, lockErr error
+	cmds := []Command{{
+		Name: "upload",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			checkpointErr = Checkpoint(ctx, "../escape", 1)
+			_, restoreErr = Restore(ctx, "a/b", new(int))
+			if len(args) == 0 {
+				return nil
+			}
+
+			failIfErr(t, Checkpoint(ctx, "part", 1))
+			// like a concurrent instance of the same command.
+			r := RunnerOf([]Command{{
+				Name: "upload",
+				ExecFunc: func(ctx context.Context, args []string) error {
+					lockErr = Checkpoint(ctx, "part", 2)
+					return nil
+				},
+			}}, Config{AppName: "myapp", Args: []string{"./myapp", "upload"}, Output: io.Discard})
+			return r.Run()
+		},
+	}}
+
+	run := func(args ...string) error {
+		r := RunnerOf(cmds, Config{
+			AppName: "myapp",
+			Args:    append([]string{"./myapp", "--resume", "upload"}, args...),
+			Output:  io.Discard,
+		})
+		return r.Run()
+	}
+
+	failIfErr(t, run())
+	failIfOk(t, checkpointErr)
+	failIfOk(t, restoreErr)
+
+	// the command didn't save or restore it, so it's kept.
+	_, err := os.Stat(other)
+	failIfErr(t, err)
+
+	failIfErr(t, run("with-lock"))
+	if !errors.Is(lockErr, ErrAlreadyRunning) {
+		t.Fatal(lockErr)
+	}
+}
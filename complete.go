@@ -1,5 +1,489 @@
 package acmd
 
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// completionShells supported by CompletionScript.
+var completionShells = []string{"bash", "elvish", "fish", "nushell", "zsh"}
+
+// completionInitShells supported by `completion init <shell>`.
+var completionInitShells = []string{"bash", "fish", "nushell", "powershell", "zsh"}
+
+// AutocompleteFor returns bash completion script for the commands,
+// name of the executable is used as the app name. See Runner.CompletionScript.
 func AutocompleteFor(cmds []Command) (string, error) {
-	return "", nil
+	cfg := &Config{AppName: filepath.Base(os.Args[0])}
+	return completionScript(cfg, cmds, "bash")
+}
+
+// CompletionScript returns a completion script for the shell (bash, elvish, fish, nushell or zsh).
+// The script is generated from the command tree: all the candidates are inlined,
+// so the binary is not invoked on completion.
+func (r *Runner) CompletionScript(shell string) (string, error) {
+	if r.errInit != nil {
+		return "", r.errInit
+	}
+	return completionScript(&r.cfg, r.cmds, shell)
+}
+
+func (r *Runner) completionCmd() Command {
+	return Command{
+		Name:        "completion",
+		Description: "prints completion script for the shell: " + strings.Join(completionShells, ", "),
+		ExecFunc: builtinFunc(func(ctx context.Context, args []string) error {
+			if len(args) == 2 && args[0] == "init" {
+				snippet, err := completionInit(filepath.Base(r.cfg.AppName), args[1])
+				if err != nil {
+					return err
+				}
+				fmt.Fprint(r.cfg.Output, snippet)
+				return nil
+			}
+			if len(args) != 1 {
+				return fmt.Errorf("usage: completion <%s> or completion init <%s>",
+					strings.Join(completionShells, "|"), strings.Join(completionInitShells, "|"))
+			}
+			script, err := completionScript(&r.cfg, r.cmds, args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(r.cfg.Output, script)
+			return nil
+		}),
+	}
+}
+
+// completionInit returns a snippet to load the completion from the shell rc file,
+// ex: `source <(app completion init zsh)`, so nothing is installed into the system dirs.
+// Bash, fish and zsh snippets load the script printed by `completion <shell>`.
+// Powershell and nushell snippets complete command names with `help --index`.
+func completionInit(app, shell string) (string, error) {
+	fn := completionFuncName(app)
+
+	switch shell {
+	case "bash":
+		return fmt.Sprintf("source <(%s completion bash)\n", shellQuote(app)), nil
+	case "zsh":
+		return "(( $+functions[compdef] )) || { autoload -Uz compinit && compinit }\n" +
+			fmt.Sprintf("source <(%s completion zsh)\n", shellQuote(app)), nil
+	case "fish":
+		return fmt.Sprintf("%s completion fish | source\n", shellQuote(app)), nil
+	case "powershell":
+		app := "'" + strings.ReplaceAll(app, "'", "''") + "'"
+		return fmt.Sprintf(powershellInit, app, app), nil
+	case "nushell":
+		return fmt.Sprintf(nushellInit, fn, strconv.Quote(app), strconv.Quote(app), fn, fn), nil
+	default:
+		return "", fmt.Errorf("unsupported shell %q, expected one of: %s", shell, strings.Join(completionInitShells, ", "))
+	}
+}
+
+const powershellInit = `Register-ArgumentCompleter -Native -CommandName %s -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { "$_" })
+    if ($wordToComplete) { $words = @($words | Select-Object -SkipLast 1) }
+    $parent = @($words | Where-Object { $_ -notlike '-*' }) -join ' '
+    & %s help --index | ForEach-Object {
+        $name, $path, $desc = $_ -split "` + "`" + `t", 3
+        $cmdParent = @($path -split ' ' | Select-Object -SkipLast 1) -join ' '
+        if ($cmdParent -eq $parent -and $name -like "$wordToComplete*") {
+            $tip = if ($desc) { $desc } else { $name }
+            [System.Management.Automation.CompletionResult]::new($name, $name, 'ParameterValue', $tip)
+        }
+    }
+}
+`
+
+const nushellInit = `let %s_prev = $env.config.completions.external.completer?
+$env.config.completions.external.enable = true
+$env.config.completions.external.completer = {|spans|
+    if $spans.0 == %s {
+        let parent = ($spans | skip 1 | drop 1 | where {|s| not ($s | str starts-with '-') } | str join ' ')
+        ^%s help --index | lines | parse "{name}	{path}	{description}"
+        | where {|c| ($c.path | split row ' ' | drop 1 | str join ' ') == $parent and ($c.name | str starts-with ($spans | last)) }
+        | select name description | rename value description
+    } else if $%s_prev != null {
+        do $%s_prev $spans
+    }
+}
+`
+
+// completionFuncName for the app to be used as a shell function or variable name.
+func completionFuncName(app string) string {
+	return "_" + strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, app)
+}
+
+// autocompleteEntry is a completion candidate.
+type autocompleteEntry struct {
+	name        string
+	description string
+}
+
+// globalValueFlags are global flags of the Runner with a value in the next arg, see parseGlobalFlags.
+// --bench is added only if Config.BenchFlag is true.
+var globalValueFlags = []string{"--output", "--seed"}
+
+// completionTree returns candidates by the command path (names or aliases joined by space, "" for the root).
+// Candidates of the command are its subcommands (with aliases) or its first arg choices and flags.
+// Leaf commands (without subcommands) are reported in leaves, their candidates are used after any args.
+// Flags with a value in the next arg are reported in valueFlags, their values are not a part of the path.
+func completionTree(cfg *Config, cmds []Command) (keys []string, tree map[string][]autocompleteEntry, leaves map[string]bool, valueFlags []string) {
+	tree = map[string][]autocompleteEntry{"": nil}
+	keys = []string{""}
+	leaves = map[string]bool{}
+	valueFlags = append(valueFlags, globalValueFlags...)
+	if cfg.BenchFlag {
+		valueFlags = append(valueFlags, "--bench")
+	}
+
+	// paths of the command by its names path, each command in a path can be typed with its alias.
+	paths := map[string][]string{"": {""}}
+
+	walkVisible(cfg, cmds, nil, func(path []string, cmd *Command) bool {
+		names := []string{cmd.Name}
+		if cmd.Alias != "" {
+			names = append(names, cmd.Alias)
+		}
+
+		var cmdPaths []string
+		for _, parent := range paths[strings.Join(path[:len(path)-1], " ")] {
+			for _, name := range names {
+				tree[parent] = append(tree[parent], autocompleteEntry{name: name, description: cmd.Description})
+				cmdPaths = append(cmdPaths, strings.TrimSpace(parent+" "+name))
+			}
+		}
+		paths[strings.Join(path, " ")] = cmdPaths
+
+		var entries []autocompleteEntry
+		if len(cmd.Subcommands) == 0 {
+			if len(cmd.ArgChoices) != 0 {
+				for _, choice := range cmd.ArgChoices[0] {
+					entries = append(entries, autocompleteEntry{name: choice})
+				}
+			}
+			if cmd.FlagSet != nil {
+				cmd.FlagSet.Flags().VisitAll(func(f *flag.Flag) {
+					entries = append(entries, autocompleteEntry{name: "-" + f.Name, description: f.Usage})
+					if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !bf.IsBoolFlag() {
+						valueFlags = append(valueFlags, "-"+f.Name, "--"+f.Name)
+					}
+				})
+			}
+		}
+		if len(cmd.Subcommands) != 0 || len(entries) != 0 {
+			for _, key := range cmdPaths {
+				keys = append(keys, key)
+				tree[key] = entries
+				leaves[key] = len(cmd.Subcommands) == 0
+			}
+		}
+		return true
+	})
+
+	for _, key := range keys {
+		entries := tree[key]
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	}
+	sort.Strings(valueFlags)
+	valueFlags = uniqueStrings(valueFlags)
+	return keys, tree, leaves, valueFlags
+}
+
+// uniqueStrings removes adjacent duplicates from sorted ss.
+func uniqueStrings(ss []string) []string {
+	res := ss[:0]
+	for i, s := range ss {
+		if i == 0 || s != ss[i-1] {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func completionScript(cfg *Config, cmds []Command, shell string) (string, error) {
+	keys, tree, leaves, valueFlags := completionTree(cfg, cmds)
+	app := filepath.Base(cfg.AppName)
+	fn := completionFuncName(app)
+
+	sb := &strings.Builder{}
+	switch shell {
+	case "bash":
+		bashCompletion(sb, app, fn, keys, tree, leaves, valueFlags)
+	case "zsh":
+		zshCompletion(sb, app, fn, keys, tree, leaves, valueFlags)
+	case "fish":
+		fishCompletion(sb, app, fn, keys, tree, leaves, valueFlags)
+	case "nushell":
+		nushellCompletion(sb, app, fn, keys, tree, leaves, valueFlags)
+	case "elvish":
+		elvishCompletion(sb, app, fn, keys, tree, leaves, valueFlags)
+	default:
+		return "", fmt.Errorf("unsupported shell %q, expected one of: %s", shell, strings.Join(completionShells, ", "))
+	}
+	return sb.String(), nil
+}
+
+// casePattern for the command path in bash and zsh case statement,
+// leaf command matches also when followed by args.
+func casePattern(key string, isLeaf bool) string {
+	if isLeaf {
+		return shellQuote(key) + "|" + shellQuote(key+" ") + "*"
+	}
+	return shellQuote(key)
+}
+
+// flagsPattern for the flags in bash and zsh case statement.
+func flagsPattern(flags []string) string {
+	return strings.Join(quoteAll(flags, shellQuote), "|")
+}
+
+// shellQuote s in single quotes for bash, zsh and fish.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func bashCompletion(sb *strings.Builder, app, fn string, keys []string, tree map[string][]autocompleteEntry, leaves map[string]bool, valueFlags []string) {
+	fmt.Fprintf(sb, "# bash completion for %s, generated by acmd.\n", app)
+	fmt.Fprintf(sb, "%s_completion() {\n", fn)
+	sb.WriteString(`    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local cmdpath="" word i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        case "$word" in
+`)
+	fmt.Fprintf(sb, "        %s) ((i++)) ;;\n", flagsPattern(valueFlags))
+	sb.WriteString(`        -*) ;;
+        *) cmdpath="${cmdpath:+$cmdpath }$word" ;;
+        esac
+    done
+    case "$cmdpath" in
+`)
+	for _, key := range keys {
+		names := make([]string, len(tree[key]))
+		for i, e := range tree[key] {
+			names[i] = e.name
+		}
+		fmt.Fprintf(sb, "        %s) COMPREPLY=($(compgen -W %s -- \"$cur\")) ;;\n",
+			casePattern(key, leaves[key]), shellQuote(strings.Join(names, " ")))
+	}
+	sb.WriteString(`    esac
+}
+`)
+	fmt.Fprintf(sb, "complete -F %s_completion %s\n", fn, app)
+}
+
+func zshCompletion(sb *strings.Builder, app, fn string, keys []string, tree map[string][]autocompleteEntry, leaves map[string]bool, valueFlags []string) {
+	fmt.Fprintf(sb, "#compdef %s\n", app)
+	fmt.Fprintf(sb, "# zsh completion for %s, generated by acmd.\n", app)
+	fmt.Fprintf(sb, "%s() {\n", fn)
+	sb.WriteString(`    local -a candidates
+    local cmdpath="" word i
+    for ((i = 2; i < CURRENT; i++)); do
+        word="${words[i]}"
+        case "$word" in
+`)
+	fmt.Fprintf(sb, "        %s) ((i++)) ;;\n", flagsPattern(valueFlags))
+	sb.WriteString(`        -*) ;;
+        *) cmdpath="${cmdpath:+$cmdpath }$word" ;;
+        esac
+    done
+    case "$cmdpath" in
+`)
+	for _, key := range keys {
+		items := make([]string, len(tree[key]))
+		for i, e := range tree[key] {
+			item := strings.ReplaceAll(e.name, ":", `\:`)
+			if e.description != "" {
+				item += ":" + e.description
+			}
+			items[i] = shellQuote(item)
+		}
+		fmt.Fprintf(sb, "        %s) candidates=(%s) ;;\n", casePattern(key, leaves[key]), strings.Join(items, " "))
+	}
+	sb.WriteString(`        *) return 1 ;;
+    esac
+    _describe 'command' candidates
+}
+`)
+	fmt.Fprintf(sb, "compdef %s %s\n", fn, app)
+}
+
+func fishCompletion(sb *strings.Builder, app, fn string, keys []string, tree map[string][]autocompleteEntry, leaves map[string]bool, valueFlags []string) {
+	var parents, leafKeys []string
+	for _, key := range keys {
+		if leaves[key] {
+			leafKeys = append(leafKeys, shellQuote(key))
+		} else {
+			parents = append(parents, shellQuote(key))
+		}
+	}
+
+	fmt.Fprintf(sb, "# fish completion for %s, generated by acmd.\n", app)
+	fmt.Fprintf(sb, "set -g %s_parents %s\n", fn, strings.Join(parents, " "))
+	fmt.Fprintf(sb, "set -g %s_leaves %s\n", fn, strings.Join(leafKeys, " "))
+	fmt.Fprintf(sb, "set -g %s_value_flags %s\n\n", fn, strings.Join(quoteAll(valueFlags, shellQuote), " "))
+	fmt.Fprintf(sb, "function %s_at -a want\n", fn)
+	sb.WriteString(`    set -l p
+    set -l skip 0
+    for t in (commandline -opc)[2..-1]
+        if test $skip = 1
+            set skip 0
+`)
+	fmt.Fprintf(sb, "        else if contains -- $t $%s_value_flags\n", fn)
+	sb.WriteString(`            set skip 1
+        else if not string match -q -- '-*' $t
+            set p $p $t
+        end
+    end
+    set -l k (string join ' ' -- $p)
+`)
+	fmt.Fprintf(sb, "    if contains -- \"$k\" $%s_parents\n", fn)
+	sb.WriteString(`        test "$k" = "$want"
+        return
+    end
+`)
+	fmt.Fprintf(sb, "    for leaf in $%s_leaves\n", fn)
+	sb.WriteString(`        if test "$k" = "$leaf"; or string match -q -- "$leaf *" "$k"
+            test "$leaf" = "$want"
+            return
+        end
+    end
+    return 1
+end
+
+`)
+	fmt.Fprintf(sb, "complete -c %s -f\n", app)
+	for _, key := range keys {
+		for _, e := range tree[key] {
+			fmt.Fprintf(sb, "complete -c %s -n %s -a %s", app, shellQuote(fn+"_at "+shellQuote(key)), shellQuote(e.name))
+			if e.description != "" {
+				fmt.Fprintf(sb, " -d %s", shellQuote(e.description))
+			}
+			sb.WriteString("\n")
+		}
+	}
+}
+
+func nushellCompletion(sb *strings.Builder, app, fn string, keys []string, tree map[string][]autocompleteEntry, leaves map[string]bool, valueFlags []string) {
+	fmt.Fprintf(sb, "# nushell completion for %s, generated by acmd.\n", app)
+	fmt.Fprintf(sb, "let %s_tree = [[key value description];\n", fn)
+	for _, key := range keys {
+		for _, e := range tree[key] {
+			fmt.Fprintf(sb, "    [%s %s %s]\n", strconv.Quote(key), strconv.Quote(e.name), strconv.Quote(e.description))
+		}
+	}
+	sb.WriteString("]\n")
+
+	var leafKeys []string
+	for _, key := range keys {
+		if leaves[key] {
+			leafKeys = append(leafKeys, strconv.Quote(key))
+		}
+	}
+	fmt.Fprintf(sb, "let %s_leaves = [%s]\n", fn, strings.Join(leafKeys, " "))
+	fmt.Fprintf(sb, "let %s_value_flags = [%s]\n", fn, strings.Join(quoteAll(valueFlags, strconv.Quote), " "))
+	fmt.Fprintf(sb, "let %s_prev = $env.config.completions.external.completer?\n", fn)
+	sb.WriteString(`$env.config.completions.external.enable = true
+$env.config.completions.external.completer = {|spans|
+`)
+	fmt.Fprintf(sb, "    if $spans.0 == %s {\n", strconv.Quote(app))
+	sb.WriteString(`        let words = ($spans | skip 1 | drop 1)
+        let path = ($words | enumerate | where {|w|
+            let prev = if $w.index > 0 { $words | get ($w.index - 1) } else { "" }
+`)
+	fmt.Fprintf(sb, "            not ($w.item | str starts-with '-') and not ($prev in $%s_value_flags)\n", fn)
+	sb.WriteString(`        } | get item | str join ' ')
+`)
+	fmt.Fprintf(sb, "        let leaf = ($%s_leaves | where {|l| $path == $l or ($path | str starts-with $\"($l) \") } | get 0?)\n", fn)
+	sb.WriteString(`        let key = if $leaf != null { $leaf } else { $path }
+`)
+	fmt.Fprintf(sb, "        $%s_tree | where {|c| $c.key == $key and ($c.value | str starts-with ($spans | last)) } | select value description\n", fn)
+	fmt.Fprintf(sb, "    } else if $%s_prev != null {\n", fn)
+	fmt.Fprintf(sb, "        do $%s_prev $spans\n", fn)
+	sb.WriteString(`    }
+}
+`)
+}
+
+func elvishCompletion(sb *strings.Builder, app, fn string, keys []string, tree map[string][]autocompleteEntry, leaves map[string]bool, valueFlags []string) {
+	fmt.Fprintf(sb, "# elvish completion for %s, generated by acmd.\n", app)
+	sb.WriteString("use str\n")
+	fmt.Fprintf(sb, "var %s_tree = [\n", fn)
+	for _, key := range keys {
+		items := make([]string, len(tree[key]))
+		for i, e := range tree[key] {
+			display := e.name
+			if e.description != "" {
+				display += "  (" + e.description + ")"
+			}
+			items[i] = "[" + elvishQuote(e.name) + " " + elvishQuote(display) + "]"
+		}
+		fmt.Fprintf(sb, "    &%s=[%s]\n", elvishQuote(key), strings.Join(items, " "))
+	}
+	sb.WriteString("]\n")
+
+	var leafKeys []string
+	for _, key := range keys {
+		if leaves[key] {
+			leafKeys = append(leafKeys, elvishQuote(key))
+		}
+	}
+	fmt.Fprintf(sb, "var %s_leaves = [%s]\n", fn, strings.Join(leafKeys, " "))
+	fmt.Fprintf(sb, "var %s_value_flags = [%s]\n", fn, strings.Join(quoteAll(valueFlags, elvishQuote), " "))
+	fmt.Fprintf(sb, "set edit:completion:arg-completer[%s] = {|@words|\n", elvishQuote(app))
+	sb.WriteString(`    var path = []
+    var skip = $false
+    for w $words[1..-1] {
+        if $skip {
+            set skip = $false
+`)
+	fmt.Fprintf(sb, "        } elif (has-value $%s_value_flags $w) {\n", fn)
+	sb.WriteString(`            set skip = $true
+        } elif (not (str:has-prefix $w -)) {
+            set path = [$@path $w]
+        }
+    }
+    var key = (str:join ' ' $path)
+`)
+	fmt.Fprintf(sb, "    for leaf $%s_leaves {\n", fn)
+	sb.WriteString(`        if (or (eq $key $leaf) (str:has-prefix $key $leaf' ')) {
+            set key = $leaf
+            break
+        }
+    }
+`)
+	fmt.Fprintf(sb, "    if (has-key $%s_tree $key) {\n", fn)
+	fmt.Fprintf(sb, "        for c $%s_tree[$key] {\n", fn)
+	sb.WriteString(`            edit:complex-candidate $c[0] &display=$c[1]
+        }
+    }
+}
+`)
+}
+
+// quoteAll ss with the quote func.
+func quoteAll(ss []string, quote func(string) string) []string {
+	res := make([]string, len(ss))
+	for i, s := range ss {
+		res[i] = quote(s)
+	}
+	return res
+}
+
+// elvishQuote s in single quotes for elvish.
+func elvishQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
 }
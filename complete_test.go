@@ -0,0 +1,202 @@
+package acmd
+
+import (
+	"flag"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func testCompletionRunner(t *testing.T) *Runner {
+	fset := flag.NewFlagSet("export", flag.ContinueOnError)
+	fset.String("o", "", "output file")
+
+	cmds := []Command{
+		{Name: "now", Description: "prints current time", ExecFunc: nopFunc},
+		{Name: "export", Description: "exports data", ExecFunc: nopFunc,
+			ArgChoices: [][]string{{"users", "groups"}},
+			FlagSet:    &copyCmd{fset: fset},
+		},
+		{Name: "time", Alias: "t", Description: "time's commands", Subcommands: []Command{
+			{Name: "next", Description: "next time", ExecFunc: nopFunc},
+			{Name: "zone", Subcommands: []Command{
+				{Name: "list", ExecFunc: nopFunc},
+			}},
+		}},
+		{Name: "secret", IsHidden: true, ExecFunc: nopFunc},
+	}
+
+	r := RunnerOf(cmds, Config{
+		AppName:    "my-app",
+		Args:       []string{"./my-app", "now"},
+		Output:     io.Discard,
+		Completion: true,
+	})
+	failIfErr(t, r.Run())
+	return r
+}
+
+func TestCompletionScriptBash(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	script, err := testCompletionRunner(t).CompletionScript("bash")
+	failIfErr(t, err)
+
+	testCases := []struct {
+		line string
+		want string
+	}{
+		{"my-app ", "completion export help now t time version"},
+		{"my-app ti", "time"},
+		{"my-app time ", "next zone"},
+		{"my-app t ", "next zone"},
+		{"my-app t zone ", "list"},
+		{"my-app --seed 5 time ", "next zone"},
+		{"my-app time zone ", "list"},
+		{"my-app export ", "-o groups users"},
+		{"my-app export -o out.txt u", "users"},
+		{"my-app export -o users ", "-o groups users"},
+		{"my-app secret ", ""},
+		{"my-app time zone list ", ""},
+	}
+
+	for _, tc := range testCases {
+		words := strings.Split(tc.line, " ")
+		run := script + `
+COMP_WORDS=(` + strings.Join(quoteAll(words, shellQuote), " ") + `)
+COMP_CWORD=` + strconv.Itoa(len(words)-1) + `
+_my_app_completion
+echo "${COMPREPLY[@]}"
+`
+		out, err := exec.Command(bash, "-c", run).CombinedOutput()
+		failIfErr(t, err)
+		got := strings.Fields(string(out))
+		sort.Strings(got)
+		mustEqual(t, strings.Join(got, " "), tc.want)
+	}
+}
+
+func TestCompletionScript(t *testing.T) {
+	r := testCompletionRunner(t)
+
+	zsh, err := r.CompletionScript("zsh")
+	failIfErr(t, err)
+	for _, want := range []string{
+		"#compdef my-app\n",
+		`        '') candidates=('completion:prints completion script for the shell: bash, elvish, fish, nushell, zsh' 'export:exports data' 'help:shows help message' 'now:prints current time' 't:time'\''s commands' 'time:time'\''s commands' 'version:shows version of the application') ;;` + "\n",
+		`        'time zone') candidates=('list') ;;` + "\n",
+		`        't zone') candidates=('list') ;;` + "\n",
+		`        'export'|'export '*) candidates=('-o:output file' 'groups' 'users') ;;` + "\n",
+		`        '--o'|'--output'|'--seed'|'-o') ((i++)) ;;` + "\n",
+		"compdef _my_app my-app\n",
+	} {
+		if !strings.Contains(zsh, want) {
+			t.Fatalf("zsh script must contain %q, got:\n%s", want, zsh)
+		}
+	}
+
+	fish, err := r.CompletionScript("fish")
+	failIfErr(t, err)
+	for _, want := range []string{
+		"set -g _my_app_parents '' 'time' 't' 'time zone' 't zone'\n",
+		"set -g _my_app_leaves 'export'\n",
+		"set -g _my_app_value_flags '--o' '--output' '--seed' '-o'\n",
+		`complete -c my-app -n '_my_app_at '\'''\''' -a 'now' -d 'prints current time'` + "\n",
+		`complete -c my-app -n '_my_app_at '\''export'\''' -a '-o' -d 'output file'` + "\n",
+	} {
+		if !strings.Contains(fish, want) {
+			t.Fatalf("fish script must contain %q, got:\n%s", want, fish)
+		}
+	}
+
+	nushell, err := r.CompletionScript("nushell")
+	failIfErr(t, err)
+	for _, want := range []string{
+		"let _my_app_tree = [[key value description];\n",
+		`    ["" "now" "prints current time"]` + "\n",
+		`    ["time zone" "list" ""]` + "\n",
+		`    ["export" "-o" "output file"]` + "\n",
+		`let _my_app_leaves = ["export"]` + "\n",
+		`let _my_app_value_flags = ["--o" "--output" "--seed" "-o"]` + "\n",
+		`    ["t zone" "list" ""]` + "\n",
+		`    if $spans.0 == "my-app" {` + "\n",
+	} {
+		if !strings.Contains(nushell, want) {
+			t.Fatalf("nushell script must contain %q, got:\n%s", want, nushell)
+		}
+	}
+
+	elvish, err := r.CompletionScript("elvish")
+	failIfErr(t, err)
+	for _, want := range []string{
+		"var _my_app_tree = [\n",
+		`    &'time zone'=[['list' 'list']]` + "\n",
+		`    &'export'=[['-o' '-o  (output file)'] ['groups' 'groups'] ['users' 'users']]` + "\n",
+		"var _my_app_leaves = ['export']\n",
+		"var _my_app_value_flags = ['--o' '--output' '--seed' '-o']\n",
+		`    &'t zone'=[['list' 'list']]` + "\n",
+		"set edit:completion:arg-completer['my-app'] = {|@words|\n",
+	} {
+		if !strings.Contains(elvish, want) {
+			t.Fatalf("elvish script must contain %q, got:\n%s", want, elvish)
+		}
+	}
+	if !strings.Contains(elvish, `['time' 'time  (time''s commands)']`) {
+		t.Fatal(elvish)
+	}
+
+	_, err = r.CompletionScript("tcsh")
+	mustEqual(t, err.Error(), `unsupported shell "tcsh", expected one of: bash, elvish, fish, nushell, zsh`)
+}
+
+func TestCompletionInit(t *testing.T) {
+	r := testCompletionRunner(t)
+	script, err := r.CompletionScript("bash")
+	failIfErr(t, err)
+
+	snippet, err := completionInit("my-app", "bash")
+	failIfErr(t, err)
+	mustEqual(t, snippet, "source <('my-app' completion bash)\n")
+
+	if bash, err := exec.LookPath("bash"); err == nil {
+		// my-app is mocked with a function printing the script.
+		run := "my-app() { printf '%s' " + shellQuote(script) + "; }\n" +
+			"eval \"$(printf '%s' " + shellQuote(snippet) + ")\"\n" +
+			"complete -p my-app\n"
+		out, err := exec.Command(bash, "-c", run).CombinedOutput()
+		failIfErr(t, err)
+		mustEqual(t, string(out), "complete -F _my_app_completion my-app\n")
+	}
+
+	for shell, want := range map[string]string{
+		"zsh":        "source <('my-app' completion zsh)\n",
+		"fish":       "'my-app' completion fish | source\n",
+		"powershell": "    & 'my-app' help --index | ForEach-Object {\n",
+		"nushell":    "        ^\"my-app\" help --index | lines",
+	} {
+		snippet, err := completionInit("my-app", shell)
+		failIfErr(t, err)
+		if !strings.Contains(snippet, want) {
+			t.Fatalf("%s snippet must contain %q, got:\n%s", shell, want, snippet)
+		}
+	}
+
+	buf := &strings.Builder{}
+	r = RunnerOf([]Command{{Name: "now", ExecFunc: nopFunc}}, Config{
+		AppName:    "my-app",
+		Args:       []string{"./my-app", "completion", "init", "fish"},
+		Output:     buf,
+		Completion: true,
+	})
+	failIfErr(t, r.Run())
+	mustEqual(t, buf.String(), "'my-app' completion fish | source\n")
+
+	_, err = completionInit("my-app", "tcsh")
+	mustEqual(t, err.Error(), `unsupported shell "tcsh", expected one of: bash, fish, nushell, powershell, zsh`)
+}
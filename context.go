@@ -0,0 +1,161 @@
+package acmd
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+type invocationKey struct{}
+
+// signalContextKey marks the default signal-based context created by the Runner.
+type signalContextKey struct{}
+
+// invocation is a state of the single command run, available via context.
+type invocation struct {
+	runner     *Runner
+	path       []string
+	cleanupCtx context.Context
+
+	mu        sync.Mutex
+	undos     []func(ctx context.Context) error
+	warnings  []string
+	tempDir   string
+	stall     *stallWatchdog
+	rand      *rand.Rand
+	seed      int64
+	pidFiles  []*os.File
+	artifacts []string
+
+	checkpoints    []string
+	checkpointLock *os.File
+}
+
+func withInvocation(ctx context.Context, inv *invocation) context.Context {
+	return context.WithValue(ctx, invocationKey{}, inv)
+}
+
+// invocationFrom returns the invocation or nil if ctx isn't from the Runner.
+func invocationFrom(ctx context.Context) *invocation {
+	inv, _ := ctx.Value(invocationKey{}).(*invocation)
+	return inv
+}
+
+// RegisterUndo adds a compensation step for the command run with ctx.
+// If the command fails or is interrupted, registered steps are run in reverse order.
+// Does nothing if ctx is not the one passed to the command by the Runner.
+func RegisterUndo(ctx context.Context, fn func(ctx context.Context) error) {
+	inv := invocationFrom(ctx)
+	if inv == nil {
+		return
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.undos = append(inv.undos, fn)
+}
+
+// hasUndos reports whether any undo step is registered.
+func (inv *invocation) hasUndos() bool {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	return len(inv.undos) != 0
+}
+
+// undo runs registered undo steps in reverse order.
+// Returned error is err with undo errors attached (if any), err can be nil.
+func (inv *invocation) undo(ctx context.Context, err error) error {
+	inv.mu.Lock()
+	undos := inv.undos
+	inv.undos = nil
+	inv.mu.Unlock()
+
+	// ctx is likely cancelled already, undo steps still must be able to run.
+	ctx = inv.cleanupCtx
+
+	for i := len(undos) - 1; i >= 0; i-- {
+		undoErr := undos[i](ctx)
+		switch {
+		case undoErr == nil:
+		case err == nil:
+			err = fmt.Errorf("undo failed: %w", undoErr)
+		default:
+			err = fmt.Errorf("%w (undo failed: %v)", err, undoErr)
+		}
+	}
+	return err
+}
+
+// CleanupContext returns a context for the cleanup after ctx is cancelled.
+// It is cancelled only after Config.CleanupTimeout passes since ctx cancellation,
+// so the command can flush buffers, remove temporary files and so on.
+// Returns ctx if it is not the one passed to the command by the Runner.
+func CleanupContext(ctx context.Context) context.Context {
+	inv := invocationFrom(ctx)
+	if inv == nil {
+		return ctx
+	}
+	return inv.cleanupCtx
+}
+
+// cleanupContext returns a context which is cancelled after CleanupTimeout since ctx is done.
+// For the default signal-based context the app is terminated after that,
+// resources of the invocation are released and finalizers are run before.
+func (r *Runner) cleanupContext(ctx context.Context, inv *invocation) (context.Context, func()) {
+	cleanupCtx, cancel := context.WithCancel(detachedContext{ctx})
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+
+		timer := time.NewTimer(r.cfg.CleanupTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		}
+		cancel()
+
+		if ctx.Value(signalContextKey{}) != nil {
+			r.terminate(inv, "%s: cleanup timeout exceeded, exiting\n", r.cfg.AppName)
+		}
+	}()
+
+	return cleanupCtx, func() {
+		close(done)
+		cancel()
+	}
+}
+
+// terminate the app while the command is still running: the message is printed,
+// resources of the invocation are released and finalizers are run.
+// Like Runner.Exit it is serialized with other exits.
+func (r *Runner) terminate(inv *invocation, format string, args ...interface{}) {
+	r.exitMu.Lock()
+	defer r.exitMu.Unlock()
+
+	r.printErr(format, args...)
+	inv.removeTempDir()
+	inv.removePIDFiles()
+	r.finalize()
+	doExit(1)
+}
+
+// detachedContext keeps values of the parent but is never cancelled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
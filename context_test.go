@@ -0,0 +1,118 @@
+package acmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRegisterUndo(t *testing.T) {
+	buf := &bytes.Buffer{}
+	errStep := errors.New("disk attach failed")
+
+	cmds := []Command{
+		{
+			Name: "create",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				fmt.Fprint(buf, "create vm;")
+				RegisterUndo(ctx, func(ctx context.Context) error {
+					fmt.Fprint(buf, "delete vm;")
+					return nil
+				})
+
+				fmt.Fprint(buf, "create disk;")
+				RegisterUndo(ctx, func(ctx context.Context) error {
+					fmt.Fprint(buf, "delete disk;")
+					return ctx.Err()
+				})
+
+				if len(args) != 0 {
+					return errStep
+				}
+				return nil
+			},
+		},
+	}
+
+	err := RunnerOf(cmds, Config{Args: []string{"./someapp", "create"}, Output: io.Discard}).Run()
+	failIfErr(t, err)
+	mustEqual(t, buf.String(), "create vm;create disk;")
+
+	buf.Reset()
+	err = RunnerOf(cmds, Config{Args: []string{"./someapp", "create", "fail"}, Output: io.Discard}).Run()
+	mustEqual(t, err, errStep)
+	mustEqual(t, buf.String(), "create vm;create disk;delete disk;delete vm;")
+
+	buf.Reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = RunnerOf(cmds, Config{Args: []string{"./someapp", "create"}, Output: io.Discard, Context: ctx}).Run()
+	failIfErr(t, err)
+	mustEqual(t, buf.String(), "create vm;create disk;delete disk;delete vm;")
+}
+
+func TestRegisterUndo_cancelledSuccess(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cmds := []Command{{
+		Name:     "status",
+		ExecFunc: func(ctx context.Context, args []string) error { return nil },
+	}}
+	err := RunnerOf(cmds, Config{Args: []string{"./someapp", "status"}, Output: io.Discard, Context: ctx}).Run()
+	failIfErr(t, err)
+}
+
+func TestRegisterUndoWithoutRunner(t *testing.T) {
+	RegisterUndo(context.Background(), func(ctx context.Context) error {
+		t.Fatal("must not be called")
+		return nil
+	})
+}
+
+func TestCleanupContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var cleanupErr error
+	var waited time.Duration
+	cmds := []Command{
+		{
+			Name: "serve",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				cleanupCtx := CleanupContext(ctx)
+
+				cancel()
+				<-ctx.Done()
+				mustEqual(t, cleanupCtx.Err(), nil)
+
+				start := time.Now()
+				<-cleanupCtx.Done()
+				waited = time.Since(start)
+				cleanupErr = cleanupCtx.Err()
+				return ctx.Err()
+			},
+		},
+	}
+
+	r := RunnerOf(cmds, Config{
+		Args:           []string{"./someapp", "serve"},
+		Output:         io.Discard,
+		Context:        ctx,
+		CleanupTimeout: 50 * time.Millisecond,
+	})
+	err := r.Run()
+	mustEqual(t, err, context.Canceled)
+	mustEqual(t, cleanupErr, context.Canceled)
+
+	if waited < 40*time.Millisecond {
+		t.Fatalf("cleanup context cancelled too early: %v", waited)
+	}
+
+	bg := context.Background()
+	mustEqual(t, CleanupContext(bg), bg)
+}
@@ -0,0 +1,36 @@
+package acmd
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+func (r *Runner) creditsCmd() Command {
+	return Command{
+		Name:        "credits",
+		Description: "shows third-party license notices",
+		ExecFunc: builtinFunc(func(ctx context.Context, args []string) error {
+			w := r.cfg.Output
+			fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(r.cfg.Licenses))
+
+			info, ok := debug.ReadBuildInfo()
+			if !ok || len(info.Deps) == 0 {
+				return nil
+			}
+
+			fmt.Fprintf(w, "Modules:\n\n")
+			t := &Table{Indent: "    "}
+			for _, dep := range info.Deps {
+				if dep.Replace != nil {
+					dep = dep.Replace
+				}
+				t.AddRow(dep.Path, dep.Version)
+			}
+			t.Render(w)
+			fmt.Fprintln(w)
+			return nil
+		}),
+	}
+}
@@ -0,0 +1,83 @@
+package acmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// checkRemoved returns an error if the command is removed in the current Config.Version.
+func (r *Runner) checkRemoved(path []string, cmd *Command) error {
+	if cmd.RemovedInVersion == "" || compareVersions(r.cfg.Version, cmd.RemovedInVersion) < 0 {
+		return nil
+	}
+	err := fmt.Errorf("command %q was removed in %s", strings.Join(path, " "), cmd.RemovedInVersion)
+	if cmd.Deprecated != "" {
+		err = fmt.Errorf("%w: %s", err, cmd.Deprecated)
+	}
+	return err
+}
+
+// warnDeprecated warns about the command which will be removed in a future version.
+func warnDeprecated(ctx context.Context, path []string, cmd *Command) {
+	if cmd.Deprecated == "" && cmd.RemovedInVersion == "" {
+		return
+	}
+
+	msg := fmt.Sprintf("command %q is deprecated", strings.Join(path, " "))
+	if cmd.RemovedInVersion != "" {
+		msg += " and will be removed in " + cmd.RemovedInVersion
+	}
+	if cmd.Deprecated != "" {
+		msg += ": " + cmd.Deprecated
+	}
+	Warn(ctx, msg)
+}
+
+// compareVersions like v1.2.3 by major, minor and patch numbers, suffixes are ignored.
+// Returns -1 if a < b, 1 if a > b and 0 if they're equal.
+// Version which cannot be parsed is less than any other.
+func compareVersions(a, b string) int {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	switch {
+	case !okA && !okB:
+		return 0
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+
+	for i := range va {
+		switch {
+		case va[i] < vb[i]:
+			return -1
+		case va[i] > vb[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+func parseVersion(s string) ([3]int, bool) {
+	var v [3]int
+	s = strings.TrimPrefix(s, "v")
+	if idx := strings.IndexAny(s, "-+"); idx != -1 {
+		s = s[:idx]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
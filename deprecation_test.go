@@ -0,0 +1,71 @@
+package acmd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDeprecatedCommand(t *testing.T) {
+	testCases := []struct {
+		version string
+		wantErr string
+		wantOut string
+	}{
+		{
+			version: "v1.9.0",
+			wantOut: `myapp: warning: command "push" is deprecated and will be removed in v2.0.0: use "deploy" instead` + "\n",
+		},
+		{
+			version: "v2.0.0",
+			wantErr: `command "push" was removed in v2.0.0: use "deploy" instead`,
+		},
+		{
+			version: "v2.1.0-rc.1",
+			wantErr: `command "push" was removed in v2.0.0: use "deploy" instead`,
+		},
+	}
+
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		cmds := []Command{{
+			Name:             "push",
+			ExecFunc:         nopFunc,
+			Deprecated:       `use "deploy" instead`,
+			RemovedInVersion: "v2.0.0",
+		}}
+
+		r := RunnerOf(cmds, Config{
+			AppName:   "myapp",
+			Args:      []string{"./myapp", "push"},
+			Version:   tc.version,
+			Output:    buf,
+			ErrOutput: buf,
+		})
+		err := r.Run()
+		if tc.wantErr != "" {
+			mustEqual(t, err.Error(), tc.wantErr)
+		} else {
+			failIfErr(t, err)
+		}
+		mustEqual(t, buf.String(), tc.wantOut)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"1.2", "v1.2.0", 0},
+		{"v1.10.0", "v1.9.9", 1},
+		{"v1.2.3", "v2", -1},
+		{"v2.0.0-beta", "v2.0.0", 0},
+		{"", "v0.0.1", -1},
+		{"(devel)", "", 0},
+	}
+
+	for _, tc := range testCases {
+		mustEqual(t, compareVersions(tc.a, tc.b), tc.want)
+	}
+}
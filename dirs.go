@@ -0,0 +1,79 @@
+package acmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// appDir returns a directory for the app inside the base dir (user config or cache dir).
+func appDir(baseDir func() (string, error), appName string) (string, error) {
+	dir, err := baseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(appName)), nil
+}
+
+// ConfigDir returns the app dir inside the user config dir, ex: `~/.config/myapp`.
+// The dir is created if it doesn't exist. Config.AppName is used as the dir name
+// or the executable name if ctx is not the one passed to the command by the Runner.
+func ConfigDir(ctx context.Context) (string, error) {
+	return makeAppDir(userConfigDir, appNameFrom(ctx))
+}
+
+// CacheDir returns the app dir inside the user cache dir, ex: `~/.cache/myapp`.
+// The dir is created if it doesn't exist. See ConfigDir.
+func CacheDir(ctx context.Context) (string, error) {
+	return makeAppDir(userCacheDir, appNameFrom(ctx))
+}
+
+func appNameFrom(ctx context.Context) string {
+	if inv := invocationFrom(ctx); inv != nil {
+		return inv.runner.cfg.AppName
+	}
+	return os.Args[0]
+}
+
+// makeAppDir is appDir which is created if needed.
+func makeAppDir(baseDir func() (string, error), appName string) (string, error) {
+	dir, err := appDir(baseDir, appName)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// runFirstRun calls Config.FirstRun if the app is run for the first time.
+// The marker file is created only after successful FirstRun.
+func (r *Runner) runFirstRun(ctx context.Context) error {
+	if r.cfg.FirstRun == nil {
+		return nil
+	}
+
+	dir, err := appDir(userConfigDir, r.cfg.AppName)
+	if err != nil {
+		return err
+	}
+	marker := filepath.Join(dir, ".first-run")
+
+	switch _, err := os.Stat(marker); {
+	case err == nil:
+		return nil
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	if err := r.cfg.FirstRun(ctx); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(marker, nil, 0o600)
+}
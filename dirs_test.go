@@ -0,0 +1,46 @@
+package acmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigAndCacheDir(t *testing.T) {
+	defer func(c, d func() (string, error)) { userConfigDir, userCacheDir = c, d }(userConfigDir, userCacheDir)
+	configDir, cacheDir := t.TempDir(), t.TempDir()
+	userConfigDir = func() (string, error) { return configDir, nil }
+	userCacheDir = func() (string, error) { return cacheDir, nil }
+
+	var gotConfig, gotCache string
+	cmds := []Command{{
+		Name: "login",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			var err error
+			gotConfig, err = ConfigDir(ctx)
+			failIfErr(t, err)
+			gotCache, err = CacheDir(ctx)
+			return err
+		},
+	}}
+	r := RunnerOf(cmds, Config{
+		AppName: "myapp",
+		Args:    []string{"./myapp", "login"},
+		Output:  io.Discard,
+	})
+	failIfErr(t, r.Run())
+
+	mustEqual(t, gotConfig, filepath.Join(configDir, "myapp"))
+	mustEqual(t, gotCache, filepath.Join(cacheDir, "myapp"))
+	for _, dir := range []string{gotConfig, gotCache} {
+		stat, err := os.Stat(dir)
+		failIfErr(t, err)
+		mustEqual(t, stat.IsDir(), true)
+	}
+
+	dir, err := ConfigDir(context.Background())
+	failIfErr(t, err)
+	mustEqual(t, dir, filepath.Join(configDir, filepath.Base(os.Args[0])))
+}
@@ -0,0 +1,66 @@
+package acmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// IsDryRun reports whether the command is run with Config.DryRun (or --dry-run flag, see Config.DryRunFlag).
+// Returns false if ctx is not the one passed to the command by the Runner.
+func IsDryRun(ctx context.Context) bool {
+	inv := invocationFrom(ctx)
+	return inv != nil && inv.runner.cfg.DryRun
+}
+
+// OpenFile is like os.OpenFile but in dry-run mode opening for writing is refused:
+// the planned action is printed to Config.Output and ErrDryRun is returned.
+// Opening for reading works as usual.
+func OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (*os.File, error) {
+	const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_APPEND | os.O_CREATE | os.O_TRUNC
+	if IsDryRun(ctx) && flag&writeFlags != 0 {
+		printPlan(ctx, "write %s", name)
+		return nil, fmt.Errorf("open %s: %w", name, ErrDryRun)
+	}
+	return os.OpenFile(name, flag, perm)
+}
+
+// HTTPClient returns a copy of http.DefaultClient, so it can be modified by the caller.
+// In dry-run mode only GET, HEAD and OPTIONS requests are sent, for others
+// the planned action is printed to Config.Output and ErrDryRun is returned.
+func HTTPClient(ctx context.Context) *http.Client {
+	client := *http.DefaultClient
+	if !IsDryRun(ctx) {
+		return &client
+	}
+	client.Transport = dryRunTransport{ctx: ctx, next: http.DefaultTransport}
+	return &client
+}
+
+type dryRunTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return t.next.RoundTrip(req)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	printPlan(t.ctx, "%s %s", req.Method, req.URL)
+	return nil, ErrDryRun
+}
+
+// printPlan prints the action which would be done without dry-run.
+func printPlan(ctx context.Context, format string, args ...interface{}) {
+	var w io.Writer = os.Stdout
+	if inv := invocationFrom(ctx); inv != nil {
+		w = inv.runner.cfg.Output
+	}
+	fmt.Fprintf(w, "dry-run: would "+format+"\n", args...)
+}
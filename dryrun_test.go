@@ -0,0 +1,90 @@
+package acmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "file")
+	failIfErr(t, os.WriteFile(name, []byte("data"), 0o600))
+
+	buf := &bytes.Buffer{}
+	cmds := []Command{{
+		Name: "foo",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			mustEqual(t, IsDryRun(ctx), true)
+
+			f, err := OpenFile(ctx, name, os.O_RDONLY, 0)
+			failIfErr(t, err)
+			f.Close()
+
+			_, err = OpenFile(ctx, name, os.O_WRONLY|os.O_TRUNC, 0)
+			if !errors.Is(err, ErrDryRun) {
+				t.Fatal(err)
+			}
+
+			client := HTTPClient(ctx)
+			resp, err := client.Get(srv.URL)
+			failIfErr(t, err)
+			resp.Body.Close()
+
+			_, err = client.Post(srv.URL, "text/plain", nil)
+			if !errors.Is(err, ErrDryRun) {
+				t.Fatal(err)
+			}
+			return nil
+		},
+	}}
+
+	r := RunnerOf(cmds, Config{
+		Args:       []string{"./someapp", "--dry-run", "foo"},
+		Output:     buf,
+		DryRunFlag: true,
+	})
+	failIfErr(t, r.Run())
+
+	want := "dry-run: would write " + name + "\n" +
+		"dry-run: would POST " + srv.URL + "\n"
+	mustEqual(t, buf.String(), want)
+
+	data, err := os.ReadFile(name)
+	failIfErr(t, err)
+	mustEqual(t, string(data), "data")
+}
+
+func TestDryRunOutsideRunner(t *testing.T) {
+	ctx := context.Background()
+	mustEqual(t, IsDryRun(ctx), false)
+
+	client := HTTPClient(ctx)
+	if client == http.DefaultClient {
+		t.Fatal("must be a copy of http.DefaultClient")
+	}
+	mustEqual(t, client.Transport, http.DefaultClient.Transport)
+}
+
+func TestDryRunFlagDisabled(t *testing.T) {
+	cmds := []Command{{Name: "foo", ExecFunc: nopFunc}}
+	r := RunnerOf(cmds, Config{
+		Args:   []string{"./someapp", "--dry-run", "foo"},
+		Output: io.Discard,
+		Usage:  nopUsage,
+	})
+	err := r.Run()
+	if err == nil || !strings.Contains(err.Error(), `no such command "--dry-run"`) {
+		t.Fatal(err)
+	}
+}
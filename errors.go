@@ -7,9 +7,120 @@ import (
 
 var ErrNoArgs = errors.New("no args provided")
 
+// ErrNoInput is returned by interactive helpers when there is no user input.
+var ErrNoInput = errors.New("no input provided")
+
+// ErrNoProjectRoot is returned when project root cannot be found.
+var ErrNoProjectRoot = errors.New("must be run inside a project")
+
+// ErrRequiresRoot is returned when a command with RequiresRoot is run not as root.
+var ErrRequiresRoot = errors.New("must be run as root, try again with sudo")
+
+// ErrAlreadyRunning is returned by PIDFile when the pid file is held by another running process.
+var ErrAlreadyRunning = errors.New("already running")
+
+// ErrDryRun is returned by OpenFile and HTTPClient for mutations in dry-run mode.
+var ErrDryRun = errors.New("not allowed in dry-run mode")
+
 // ErrCode is a number to be returned as an exit code.
 type ErrCode int
 
 func (e ErrCode) Error() string {
 	return fmt.Sprintf("code %d", int(e))
 }
+
+// WithHint returns err with an actionable hint for the user, ex: `try running "myapp auth login"`.
+// Hint is printed by Runner.Exit after the error. Returns nil if err is nil.
+func WithHint(err error, hint string) error {
+	if err == nil {
+		return nil
+	}
+	return hintError{err: err, hint: hint}
+}
+
+// ErrorHint returns the hint added with WithHint to err or to any error it wraps.
+func ErrorHint(err error) string {
+	var hintErr hintError
+	if errors.As(err, &hintErr) {
+		return hintErr.hint
+	}
+	return ""
+}
+
+type hintError struct {
+	err  error
+	hint string
+}
+
+func (e hintError) Error() string { return e.err.Error() }
+func (e hintError) Unwrap() error { return e.err }
+
+// ErrReservedName is returned when a command name or alias is reserved by a builtin command.
+type ErrReservedName struct {
+	Name    string // reserved name
+	Path    string // full path of the command, ex: `time next`
+	IsAlias bool   // whether Name is an alias of the command
+}
+
+func (e ErrReservedName) Error() string {
+	return fmt.Sprintf("%s %q is reserved", nameKind(e.IsAlias), e.Name)
+}
+
+// ErrDuplicateCommand is returned when a command name or alias is used twice on the same level.
+type ErrDuplicateCommand struct {
+	Name    string // duplicated name
+	Path    string // full path of the second command, ex: `time next`
+	IsAlias bool   // whether Name is an alias of the command
+}
+
+func (e ErrDuplicateCommand) Error() string {
+	return fmt.Sprintf("duplicate %s %q", nameKind(e.IsAlias), e.Name)
+}
+
+// ErrInvalidName is returned when a command name or alias is banned or doesn't follow the rules.
+type ErrInvalidName struct {
+	Name    string // invalid name
+	Path    string // full path of the command, ex: `time next`
+	IsAlias bool   // whether Name is an alias of the command
+	Rule    string // violated rule, ex: `is banned`
+}
+
+func (e ErrInvalidName) Error() string {
+	return fmt.Sprintf("%s %q %s", nameKind(e.IsAlias), e.Name, e.Rule)
+}
+
+// ErrUnknownCommand is returned when there is no command for the args, see Runner.Resolve.
+// Runner.Run prints it with the suggestions and the usage hint.
+type ErrUnknownCommand struct {
+	Name        string   // unknown name
+	Path        string   // full path of the unknown command, ex: `time nxt`
+	Suggestions []string // similar commands on the same level, see Config.Suggest
+
+	parent    []string
+	parentCmd *Command
+	cmds      []Command
+}
+
+func (e ErrUnknownCommand) Error() string {
+	return fmt.Sprintf("no such command %q", e.Path)
+}
+
+// ErrFlagParse is returned by ParseFlags when the command flags cannot be parsed.
+type ErrFlagParse struct {
+	Path string // full path of the command, ex: `time next`
+	Flag string // flag name without dashes, empty if unknown
+	Err  error  // error from the flag package
+}
+
+func (e ErrFlagParse) Error() string {
+	return fmt.Sprintf("in command %q: %v", e.Path, e.Err)
+}
+
+func (e ErrFlagParse) Unwrap() error { return e.Err }
+
+func nameKind(isAlias bool) string {
+	if isAlias {
+		return "command alias"
+	}
+	return "command"
+}
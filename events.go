@@ -0,0 +1,43 @@
+package acmd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Event is a single JSON line written to Config.EventOutput.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"` // start, progress, warning or end.
+	Command string    `json:"command,omitempty"`
+	Args    []string  `json:"args,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Code    *int      `json:"code,omitempty"` // exit code, set only for end event.
+}
+
+// Progress reports a progress of the command run with ctx as an event.
+// Does nothing if Config.EventOutput is not set or ctx is not from the Runner.
+func Progress(ctx context.Context, msg string) {
+	inv := invocationFrom(ctx)
+	if inv == nil {
+		return
+	}
+	if inv.stall != nil {
+		inv.stall.touch()
+	}
+	inv.runner.emitEvent(Event{Type: "progress", Command: strings.Join(inv.path, " "), Message: msg})
+}
+
+// emitEvent writes the event to Config.EventOutput if it's set.
+func (r *Runner) emitEvent(e Event) {
+	if r.cfg.EventOutput == nil {
+		return
+	}
+	e.Time = time.Now()
+
+	r.eventMu.Lock()
+	defer r.eventMu.Unlock()
+	json.NewEncoder(r.cfg.EventOutput).Encode(e)
+}
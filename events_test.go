@@ -0,0 +1,93 @@
+package acmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEventOutput(t *testing.T) {
+	events := &bytes.Buffer{}
+	cmds := []Command{{
+		Name: "foo",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			Progress(ctx, "half done")
+			return ErrCode(3)
+		},
+	}}
+
+	r := RunnerOf(cmds, Config{
+		Args:      []string{"./someapp", "--output=jsonl", "foo", "bar"},
+		Output:    &bytes.Buffer{},
+		ErrOutput: events,
+	})
+	failIfOk(t, r.Run())
+
+	code := 3
+	var got []Event
+	sc := bufio.NewScanner(events)
+	for sc.Scan() {
+		var e Event
+		failIfErr(t, json.Unmarshal(sc.Bytes(), &e))
+		if e.Time.IsZero() {
+			t.Fatalf("no time in %s", sc.Text())
+		}
+		got = append(got, Event{Type: e.Type, Command: e.Command, Args: e.Args, Message: e.Message, Code: e.Code})
+	}
+
+	want := []Event{
+		{Type: "start", Command: "foo", Args: []string{"bar"}},
+		{Type: "progress", Command: "foo", Message: "half done"},
+		{Type: "end", Command: "foo", Message: "code 3", Code: &code},
+	}
+	mustEqual(t, got, want)
+}
+
+func TestEventOutput_codeOnlyForEnd(t *testing.T) {
+	events := &bytes.Buffer{}
+	cmds := []Command{{
+		Name: "foo",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			Progress(ctx, "half done")
+			return nil
+		},
+	}}
+
+	r := RunnerOf(cmds, Config{
+		Args:      []string{"./someapp", "--output", "jsonl", "foo"},
+		Output:    &bytes.Buffer{},
+		ErrOutput: events,
+	})
+	failIfErr(t, r.Run())
+
+	lines := strings.Split(strings.TrimSpace(events.String()), "\n")
+	mustEqual(t, len(lines), 3)
+	for _, line := range lines[:2] {
+		if strings.Contains(line, `"code"`) {
+			t.Fatal(line)
+		}
+	}
+	if !strings.HasSuffix(lines[2], `"code":0}`) {
+		t.Fatal(lines[2])
+	}
+}
+
+func TestProgressWithoutEventOutput(t *testing.T) {
+	Progress(context.Background(), "nothing")
+
+	cmds := []Command{{
+		Name: "foo",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			Progress(ctx, "nothing")
+			return nil
+		},
+	}}
+	r := RunnerOf(cmds, Config{
+		Args:   []string{"./someapp", "foo"},
+		Output: &bytes.Buffer{},
+	})
+	failIfErr(t, r.Run())
+}
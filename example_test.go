@@ -144,6 +144,342 @@ func Example_verboseHelp() {
 	// Version: the best v0.x.y
 }
 
+func Example_treeUsage() {
+	testOut := os.Stdout
+	testArgs := []string{"someapp", "help"}
+
+	cmds := []acmd.Command{
+		{
+			Name:        "now",
+			Description: "prints current time",
+			ExecFunc:    nopFunc,
+		},
+		{
+			Name:        "time",
+			Description: "time related commands",
+			Subcommands: []acmd.Command{
+				{Name: "next", ExecFunc: nopFunc, Description: "next time subcommand"},
+				{
+					Name: "zone", Subcommands: []acmd.Command{
+						{Name: "list", ExecFunc: nopFunc, Description: "lists time zones"},
+					},
+				},
+			},
+		},
+	}
+
+	r := acmd.RunnerOf(cmds, acmd.Config{
+		AppName: "acmd-example",
+		Output:  testOut,
+		Args:    testArgs,
+		Usage:   acmd.TreeUsage,
+	})
+
+	if err := r.Run(); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// Usage:
+	//
+	//     acmd-example <command> [arguments...]
+	//
+	// The commands are:
+	//
+	//     help                   shows help message
+	//     now                    prints current time
+	//     time                   time related commands
+	//         next               next time subcommand
+	//         zone               <no description>
+	//             list           lists time zones
+	//     version                shows version of the application
+}
+
+func Example_defaultUsage() {
+	testOut := os.Stdout
+	testArgs := []string{"someapp", "help"}
+
+	cmds := []acmd.Command{
+		{Name: "now", Description: "prints current time", ExecFunc: nopFunc},
+	}
+
+	r := acmd.RunnerOf(cmds, acmd.Config{
+		AppName: "acmd-example",
+		Output:  testOut,
+		Args:    testArgs,
+		Usage: func(cfg acmd.Config, cmds []acmd.Command) {
+			acmd.DefaultUsage(cfg, cmds, cfg.Output)
+			fmt.Fprintf(cfg.Output, "Examples:\n\n    acmd-example now\n")
+		},
+	})
+
+	if err := r.Run(); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// Usage:
+	//
+	//     acmd-example <command> [arguments...]
+	//
+	// The commands are:
+	//
+	//     help              shows help message
+	//     now               prints current time
+	//     version           shows version of the application
+	//
+	// Examples:
+	//
+	//     acmd-example now
+}
+
+func Example_resourceCommands() {
+	testOut := os.Stdout
+	testArgs := []string{"someapp", "help"}
+
+	cmds := []acmd.Command{
+		acmd.ResourceCommands("pod", map[string]func(ctx context.Context, args []string) error{
+			"get":      nopFunc,
+			"describe": nopFunc,
+			"delete":   nopFunc,
+		}, nil),
+	}
+
+	r := acmd.RunnerOf(cmds, acmd.Config{
+		AppName: "acmd-example",
+		Output:  testOut,
+		Args:    testArgs,
+	})
+
+	if err := r.Run(); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// Usage:
+	//
+	//     acmd-example <command> [arguments...]
+	//
+	// The commands are:
+	//
+	//     help                   shows help message
+	//     pod delete             delete pod
+	//     pod describe           describe pod
+	//     pod get                get pod
+	//     version                shows version of the application
+}
+
+func Example_mount() {
+	testOut := os.Stdout
+	testArgs := []string{"someapp", "help"}
+
+	subtoolCmds := []acmd.Command{
+		{Name: "lint", Description: "lints the code", ExecFunc: nopFunc},
+		{Name: "fmt", Description: "formats the code", ExecFunc: nopFunc},
+	}
+
+	cmds := []acmd.Command{
+		{Name: "build", Description: "builds the project", ExecFunc: nopFunc},
+		acmd.Mount("code", "code tools", subtoolCmds),
+	}
+
+	r := acmd.RunnerOf(cmds, acmd.Config{
+		AppName: "bigtool",
+		Output:  testOut,
+		Args:    testArgs,
+	})
+
+	if err := r.Run(); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// Usage:
+	//
+	//     bigtool <command> [arguments...]
+	//
+	// The commands are:
+	//
+	//     build               builds the project
+	//     code fmt            formats the code
+	//     code lint           lints the code
+	//     help                shows help message
+	//     version             shows version of the application
+}
+
+func Example_helpTopics() {
+	testOut := os.Stdout
+	testArgs := []string{"someapp", "help"}
+
+	cmds := []acmd.Command{
+		{Name: "login", Description: "logs in", ExecFunc: nopFunc},
+	}
+
+	r := acmd.RunnerOf(cmds, acmd.Config{
+		AppName: "acmd-example",
+		Output:  testOut,
+		Args:    testArgs,
+		HelpTopics: []acmd.HelpTopic{
+			{
+				Name:    "auth",
+				Title:   "authentication setup",
+				Content: "Run `acmd-example login` and follow the instructions.",
+			},
+		},
+	})
+
+	if err := r.Run(); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// Usage:
+	//
+	//     acmd-example <command> [arguments...]
+	//
+	// The commands are:
+	//
+	//     help              shows help message
+	//     login             logs in
+	//     version           shows version of the application
+	//
+	// Additional help topics:
+	//
+	//     auth           authentication setup
+}
+
+func Example_snapshot() {
+	testOut := os.Stdout
+	testArgs := []string{"someapp", "help", "--all", "--plain", "--hidden"}
+
+	cmds := []acmd.Command{
+		{
+			Name:        "now",
+			Alias:       "n",
+			Description: "prints current time",
+			ExecFunc:    nopFunc,
+			FlagSet:     &generalFlags{},
+		},
+		{
+			Name: "time", Subcommands: []acmd.Command{
+				{Name: "next", ExecFunc: nopFunc, Description: "next time subcommand", IsHidden: true},
+			},
+		},
+	}
+
+	r := acmd.RunnerOf(cmds, acmd.Config{
+		AppName: "acmd-example",
+		Output:  testOut,
+		Args:    testArgs,
+	})
+
+	if err := r.Run(); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// __resolve
+	//     description: prints how the args are resolved to a command
+	//     hidden: true
+	// help
+	//     description: shows help message
+	// now
+	//     alias: n
+	//     description: prints current time
+	//     flag: -dir string: directory to process
+	//     flag: -verbose: should app be verbose
+	// time
+	// time next
+	//     description: next time subcommand
+	//     hidden: true
+	// version
+	//     description: shows version of the application
+}
+
+func Example_helpMaxDepth() {
+	testOut := os.Stdout
+	testArgs := []string{"someapp", "help"}
+
+	cmds := []acmd.Command{
+		{Name: "now", Alias: "n", Description: "prints current time", ExecFunc: nopFunc},
+		{
+			Name: "time", Subcommands: []acmd.Command{
+				{Name: "next", ExecFunc: nopFunc, Description: "next time subcommand"},
+				{
+					Name: "zone", Subcommands: []acmd.Command{
+						{Name: "list", ExecFunc: nopFunc, Description: "lists time zones"},
+					},
+				},
+			},
+		},
+	}
+
+	r := acmd.RunnerOf(cmds, acmd.Config{
+		AppName:      "acmd-example",
+		Output:       testOut,
+		Args:         testArgs,
+		HelpMaxDepth: 2,
+	})
+
+	if err := r.Run(); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// Usage:
+	//
+	//     acmd-example <command> [arguments...]
+	//
+	// The commands are:
+	//
+	//     help                  shows help message
+	//     now (n)               prints current time
+	//     time next             next time subcommand
+	//     time zone …           run "acmd-example help time zone" for subcommands
+	//     version               shows version of the application
+}
+
+func Example_helpCommand() {
+	testOut := os.Stdout
+	testArgs := []string{"someapp", "help", "time", "zone"}
+
+	cmds := []acmd.Command{
+		{
+			Name: "time", Subcommands: []acmd.Command{
+				{Name: "next", ExecFunc: nopFunc, Description: "next time subcommand"},
+				{
+					Name: "zone", Description: "time zone commands", Subcommands: []acmd.Command{
+						{Name: "list", ExecFunc: nopFunc, Description: "lists time zones"},
+						{Name: "set", ExecFunc: nopFunc, Description: "sets time zone", FlagSet: &generalFlags{}},
+					},
+				},
+			},
+		},
+	}
+
+	r := acmd.RunnerOf(cmds, acmd.Config{
+		AppName: "acmd-example",
+		Output:  testOut,
+		Args:    testArgs,
+	})
+
+	if err := r.Run(); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// time zone commands
+	//
+	// Usage:
+	//
+	//     acmd-example time zone <command> [arguments...]
+	//
+	// The commands are:
+	//
+	//     list           lists time zones
+	//     set            sets time zone
+}
+
 func Example_version() {
 	testOut := os.Stdout
 	testArgs := []string{"someapp", "version"}
@@ -0,0 +1,53 @@
+package acmd
+
+import "context"
+
+// RegisterFinalizer adds a step to run before the app exits with Runner.Exit
+// or is terminated after Config.CleanupTimeout, ex: to flush logs or telemetry.
+// Does nothing if ctx is not the one passed to the command by the Runner, see Runner.RegisterFinalizer.
+func RegisterFinalizer(ctx context.Context, fn func(ctx context.Context) error) {
+	if inv := invocationFrom(ctx); inv != nil {
+		inv.runner.RegisterFinalizer(fn)
+	}
+}
+
+// RegisterFinalizer adds a step to run before the app exits with Runner.Exit
+// or is terminated after Config.CleanupTimeout, ex: to flush logs or telemetry.
+// Finalizers are run once in reverse order, all together they are bounded by Config.CleanupTimeout.
+func (r *Runner) RegisterFinalizer(fn func(ctx context.Context) error) {
+	r.finalizeMu.Lock()
+	defer r.finalizeMu.Unlock()
+	r.finalizers = append(r.finalizers, fn)
+}
+
+// finalize runs registered finalizers, errors are printed to ErrOutput.
+// Waits no longer than Config.CleanupTimeout for them.
+func (r *Runner) finalize() {
+	r.finalizeMu.Lock()
+	finalizers := r.finalizers
+	r.finalizers = nil
+	r.finalizeMu.Unlock()
+
+	if len(finalizers) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.CleanupTimeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := len(finalizers) - 1; i >= 0; i-- {
+			if err := finalizers[i](ctx); err != nil {
+				r.printErr("%s: finalizer failed: %v\n", r.cfg.AppName, err)
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		r.printErr("%s: finalizers timeout exceeded\n", r.cfg.AppName)
+	}
+}
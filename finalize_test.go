@@ -0,0 +1,168 @@
+package acmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRegisterFinalizer(t *testing.T) {
+	var gotCode int
+	doExitOld := func(code int) {
+		gotCode = code
+	}
+	defer func() { doExit = doExitOld }()
+	doExitOld, doExit = doExit, doExitOld
+
+	var order []string
+	cmds := []Command{{
+		Name: "sync",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			RegisterFinalizer(ctx, func(ctx context.Context) error {
+				order = append(order, "flush logs")
+				return nil
+			})
+			return errors.New("sync failed")
+		},
+	}}
+
+	errBuf := &bytes.Buffer{}
+	r := RunnerOf(cmds, Config{
+		AppName:   "myapp",
+		Args:      []string{"./myapp", "sync"},
+		Output:    io.Discard,
+		ErrOutput: errBuf,
+	})
+	r.RegisterFinalizer(func(ctx context.Context) error {
+		order = append(order, "send telemetry")
+		return errors.New("network is down")
+	})
+
+	r.Exit(r.Run())
+	mustEqual(t, gotCode, 1)
+	mustEqual(t, order, []string{"flush logs", "send telemetry"})
+	mustEqual(t, errBuf.String(), "myapp: sync failed\nmyapp: finalizer failed: network is down\n")
+
+	// finalizers are run once.
+	r.Exit(nil)
+	mustEqual(t, len(order), 2)
+}
+
+func TestRegisterFinalizer_timeout(t *testing.T) {
+	doExitOld := func(code int) {}
+	defer func() { doExit = doExitOld }()
+	doExitOld, doExit = doExit, doExitOld
+
+	errBuf := &bytes.Buffer{}
+	r := RunnerOf([]Command{{Name: "foo", ExecFunc: nopFunc}}, Config{
+		AppName:        "myapp",
+		Args:           []string{"./myapp", "foo"},
+		Output:         io.Discard,
+		ErrOutput:      errBuf,
+		CleanupTimeout: 10 * time.Millisecond,
+	})
+	r.RegisterFinalizer(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	start := time.Now()
+	r.Exit(nil)
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("finalizers must be bounded by CleanupTimeout")
+	}
+	mustEqual(t, errBuf.String(), "myapp: finalizers timeout exceeded\n")
+}
+
+func TestCleanupTimeoutExit(t *testing.T) {
+	exited := make(chan int, 1)
+	doExitOld := func(code int) {
+		exited <- code
+	}
+	defer func() { doExit = doExitOld }()
+	doExitOld, doExit = doExit, doExitOld
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var tempDir string
+	var finalized bool
+	cmds := []Command{{
+		Name: "sync",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			var err error
+			tempDir, err = TempDir(ctx)
+			failIfErr(t, err)
+			RegisterFinalizer(ctx, func(ctx context.Context) error {
+				finalized = true
+				return nil
+			})
+			<-exited // the command hangs until the app is terminated.
+			return ctx.Err()
+		},
+	}}
+
+	errBuf := &bytes.Buffer{}
+	r := RunnerOf(cmds, Config{
+		AppName:        "myapp",
+		Args:           []string{"./myapp", "sync"},
+		Output:         io.Discard,
+		ErrOutput:      errBuf,
+		Context:        context.WithValue(ctx, signalContextKey{}, true),
+		CleanupTimeout: 10 * time.Millisecond,
+	})
+	if err := r.Run(); !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+
+	mustEqual(t, finalized, true)
+	if _, err := os.Stat(tempDir); !os.IsNotExist(err) {
+		t.Fatal("temp dir must be removed")
+	}
+	mustEqual(t, errBuf.String(), "myapp: cleanup timeout exceeded, exiting\n")
+}
+
+func TestCleanupTimeoutExit_waitsForExit(t *testing.T) {
+	exited := make(chan int, 1)
+	doExitOld := func(code int) {
+		exited <- code
+	}
+	defer func() { doExit = doExitOld }()
+	doExitOld, doExit = doExit, doExitOld
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var r *Runner
+	cmds := []Command{{
+		Name: "sync",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			r.exitMu.Lock() // like a concurrent Runner.Exit in progress.
+			cancel()
+
+			select {
+			case <-exited:
+				t.Error("must wait for the concurrent exit")
+			case <-time.After(50 * time.Millisecond):
+			}
+			r.exitMu.Unlock()
+
+			mustEqual(t, <-exited, 1)
+			return ctx.Err()
+		},
+	}}
+	r = RunnerOf(cmds, Config{
+		Args:           []string{"./myapp", "sync"},
+		Output:         io.Discard,
+		ErrOutput:      io.Discard,
+		Context:        context.WithValue(ctx, signalContextKey{}, true),
+		CleanupTimeout: 10 * time.Millisecond,
+	})
+	if err := r.Run(); !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+}
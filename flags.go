@@ -0,0 +1,177 @@
+package acmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"strings"
+)
+
+// ParseFlags parses args with fset. Failures are returned as ErrFlagParse
+// with the path of the command run with ctx, nothing is printed by fset.
+// flag.ErrHelp is returned as is.
+// With Config.UsageErrorsToStderr the error makes Runner.Exit use Config.UsageErrorCode.
+func ParseFlags(ctx context.Context, fset *flag.FlagSet, args []string) error {
+	old := fset.Output()
+	fset.SetOutput(io.Discard)
+	err := fset.Parse(args)
+	fset.SetOutput(old)
+
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+
+	parseErr := ErrFlagParse{Flag: flagFromError(err.Error()), Err: err}
+	inv := invocationFrom(ctx)
+	if inv == nil {
+		return parseErr
+	}
+	parseErr.Path = strings.Join(inv.path, " ")
+	if inv.runner.cfg.UsageErrorsToStderr {
+		return usageError{err: parseErr, code: inv.runner.cfg.UsageErrorCode}
+	}
+	return parseErr
+}
+
+// flagFromError extracts the flag name from the flag package error message.
+func flagFromError(msg string) string {
+	for _, prefix := range []string{"flag provided but not defined: ", "flag needs an argument: ", "bad flag syntax: "} {
+		if strings.HasPrefix(msg, prefix) {
+			return strings.TrimLeft(strings.TrimPrefix(msg, prefix), "-")
+		}
+	}
+	// ex: `invalid value "x" for flag -n: parse error`
+	for _, sep := range []string{" for flag -", " for -", "invalid boolean flag "} {
+		if i := strings.Index(msg, sep); i != -1 {
+			name := msg[i+len(sep):]
+			if j := strings.Index(name, ":"); j != -1 {
+				name = name[:j]
+			}
+			return name
+		}
+	}
+	return ""
+}
+
+// normalizeArgs of the command according to the config, so they can be parsed with the command FlagSet.
+func (r *Runner) normalizeArgs(cmd *Command, args []string) []string {
+	if cmd.FlagSet == nil {
+		return args
+	}
+	fset := cmd.FlagSet.Flags()
+	if r.cfg.ShortFlagClusters {
+		args = expandShortFlags(fset, args)
+	}
+	if r.cfg.PermuteFlags {
+		args = permuteFlags(fset, args)
+	}
+	return args
+}
+
+// permuteFlags moves flags before the positional args like GNU getopt does,
+// ex: `src dst --force` becomes `--force src dst`. Args after `--` are kept as is.
+func permuteFlags(fset *flag.FlagSet, args []string) []string {
+	flags := make([]string, 0, len(args))
+	var positionals []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			flags = append(flags, arg)
+			return append(append(flags, positionals...), args[i+1:]...)
+
+		case len(arg) < 2 || arg[0] != '-':
+			positionals = append(positionals, arg)
+
+		default:
+			flags = append(flags, arg)
+			if flagNeedsValue(fset, arg) && i+1 < len(args) {
+				i++
+				flags = append(flags, args[i])
+			}
+		}
+	}
+	return append(flags, positionals...)
+}
+
+// flagNeedsValue reports whether flag arg (ex: `-n` or `--name=foo`) takes the next arg as a value.
+func flagNeedsValue(fset *flag.FlagSet, arg string) bool {
+	name := strings.TrimLeft(arg, "-")
+	if strings.Contains(name, "=") {
+		return false
+	}
+	f := fset.Lookup(name)
+	if f == nil {
+		return false
+	}
+	if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && bf.IsBoolFlag() {
+		return false
+	}
+	return true
+}
+
+// expandShortFlags splits POSIX-style clusters of single-letter flags,
+// ex: `-abc` becomes `-a -b -c` and `-vn5` becomes `-v -n=5`.
+// Args which are defined flags themselves (ex: `-force`) and args after `--` are kept as is.
+func expandShortFlags(fset *flag.FlagSet, args []string) []string {
+	res := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			return append(res, args[i:]...)
+		}
+		expanded, ok := expandShortCluster(fset, arg)
+		if !ok {
+			res = append(res, arg)
+			continue
+		}
+		res = append(res, expanded...)
+	}
+	return res
+}
+
+// expandShortCluster returns false if arg is not a cluster of the defined single-letter flags.
+func expandShortCluster(fset *flag.FlagSet, arg string) ([]string, bool) {
+	if len(arg) < 3 || arg[0] != '-' || arg[1] == '-' || strings.Contains(arg, "=") {
+		return nil, false
+	}
+	if fset.Lookup(arg[1:]) != nil {
+		return nil, false
+	}
+
+	var res []string
+	for i := 1; i < len(arg); i++ {
+		name := arg[i : i+1]
+		if fset.Lookup(name) == nil {
+			return nil, false
+		}
+		if flagNeedsValue(fset, name) {
+			if i+1 == len(arg) {
+				// value is the next arg, ex: `-vn 5`.
+				return append(res, "-"+name), true
+			}
+			return append(res, "-"+name+"="+arg[i+1:]), true
+		}
+		res = append(res, "-"+name)
+	}
+	return res, true
+}
+
+// positionalArgs returns args which are not flags or flag values.
+// fset can be nil, then every arg starting with `-` is a flag without a value.
+func positionalArgs(fset *flag.FlagSet, args []string) []string {
+	var res []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return append(res, args[i+1:]...)
+		case len(arg) < 2 || arg[0] != '-':
+			res = append(res, arg)
+		case fset != nil && flagNeedsValue(fset, arg):
+			i++
+		}
+	}
+	return res
+}
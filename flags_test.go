@@ -0,0 +1,241 @@
+package acmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestPermuteFlags(t *testing.T) {
+	fset := flag.NewFlagSet("copy", flag.ContinueOnError)
+	fset.Bool("force", false, "")
+	fset.Int("n", 0, "")
+
+	testCases := []struct {
+		args []string
+		want []string
+	}{
+		{
+			args: []string{"src", "dst", "--force"},
+			want: []string{"--force", "src", "dst"},
+		},
+		{
+			args: []string{"src", "-n", "5", "dst", "-force=false"},
+			want: []string{"-n", "5", "-force=false", "src", "dst"},
+		},
+		{
+			args: []string{"src", "--n=5", "-", "dst"},
+			want: []string{"--n=5", "src", "-", "dst"},
+		},
+		{
+			args: []string{"src", "--force", "--", "-n", "dst"},
+			want: []string{"--force", "--", "src", "-n", "dst"},
+		},
+		{
+			args: []string{"src", "-unknown", "dst"},
+			want: []string{"-unknown", "src", "dst"},
+		},
+	}
+
+	for _, tc := range testCases {
+		mustEqual(t, permuteFlags(fset, tc.args), tc.want)
+	}
+}
+
+func TestExpandShortFlags(t *testing.T) {
+	fset := flag.NewFlagSet("ls", flag.ContinueOnError)
+	fset.Bool("a", false, "")
+	fset.Bool("l", false, "")
+	fset.Bool("all", false, "")
+	fset.Int("n", 0, "")
+
+	testCases := []struct {
+		args []string
+		want []string
+	}{
+		{
+			args: []string{"-al", "dir"},
+			want: []string{"-a", "-l", "dir"},
+		},
+		{
+			args: []string{"-n5", "-aln10"},
+			want: []string{"-n=5", "-a", "-l", "-n=10"},
+		},
+		{
+			args: []string{"-aln", "7"},
+			want: []string{"-a", "-l", "-n", "7"},
+		},
+		{
+			args: []string{"-all", "-ax", "--al", "-n=3"},
+			want: []string{"-all", "-ax", "--al", "-n=3"},
+		},
+		{
+			args: []string{"-la", "--", "-la"},
+			want: []string{"-l", "-a", "--", "-la"},
+		},
+	}
+
+	for _, tc := range testCases {
+		mustEqual(t, expandShortFlags(fset, tc.args), tc.want)
+	}
+}
+
+type copyCmd struct {
+	fset  *flag.FlagSet
+	force *bool
+}
+
+func (c *copyCmd) Flags() *flag.FlagSet { return c.fset }
+
+func TestRunnerPermuteFlags(t *testing.T) {
+	c := &copyCmd{fset: flag.NewFlagSet("copy", flag.ContinueOnError)}
+	c.force = c.fset.Bool("force", false, "")
+
+	var got []string
+	cmds := []Command{{
+		Name:    "copy",
+		FlagSet: c,
+		ExecFunc: func(ctx context.Context, args []string) error {
+			if err := c.fset.Parse(args); err != nil {
+				return err
+			}
+			got = c.fset.Args()
+			return nil
+		},
+	}}
+
+	r := RunnerOf(cmds, Config{
+		Args:         []string{"./someapp", "copy", "src", "dst", "--force"},
+		Output:       io.Discard,
+		PermuteFlags: true,
+	})
+	failIfErr(t, r.Run())
+
+	mustEqual(t, *c.force, true)
+	mustEqual(t, got, []string{"src", "dst"})
+}
+
+func TestRunnerShortFlagClusters(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		wantA    bool
+		wantB    bool
+		wantC    bool
+		wantN    int
+		wantArgs []string
+	}{
+		{
+			args:     []string{"-abc", "file"},
+			wantA:    true,
+			wantB:    true,
+			wantC:    true,
+			wantArgs: []string{"file"},
+		},
+		{
+			args:     []string{"-n5", "-ac", "file"},
+			wantA:    true,
+			wantC:    true,
+			wantN:    5,
+			wantArgs: []string{"file"},
+		},
+		{
+			args:     []string{"-bn", "7", "file"},
+			wantB:    true,
+			wantN:    7,
+			wantArgs: []string{"file"},
+		},
+		{
+			args:     []string{"-a", "--", "-bc", "-n5"},
+			wantA:    true,
+			wantArgs: []string{"-bc", "-n5"},
+		},
+	}
+
+	for _, tc := range testCases {
+		c := &copyCmd{fset: flag.NewFlagSet("ls", flag.ContinueOnError)}
+		a := c.fset.Bool("a", false, "")
+		b := c.fset.Bool("b", false, "")
+		cFlag := c.fset.Bool("c", false, "")
+		n := c.fset.Int("n", 0, "")
+
+		var got []string
+		cmds := []Command{{
+			Name:    "ls",
+			FlagSet: c,
+			ExecFunc: func(ctx context.Context, args []string) error {
+				if err := ParseFlags(ctx, c.fset, args); err != nil {
+					return err
+				}
+				got = c.fset.Args()
+				return nil
+			},
+		}}
+
+		r := RunnerOf(cmds, Config{
+			Args:              append([]string{"./someapp", "ls"}, tc.args...),
+			Output:            io.Discard,
+			ShortFlagClusters: true,
+		})
+		failIfErr(t, r.Run())
+
+		mustEqual(t, *a, tc.wantA)
+		mustEqual(t, *b, tc.wantB)
+		mustEqual(t, *cFlag, tc.wantC)
+		mustEqual(t, *n, tc.wantN)
+		mustEqual(t, got, tc.wantArgs)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	var gotErr error
+	cmds := []Command{{
+		Name: "test",
+		Subcommands: []Command{{
+			Name: "foo",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				fset := flag.NewFlagSet("foo", flag.ContinueOnError)
+				fset.Int("n", 1, "")
+				gotErr = ParseFlags(ctx, fset, args)
+				return gotErr
+			},
+		}},
+	}}
+
+	testCases := []struct {
+		args    []string
+		flag    string
+		wantErr string
+	}{
+		{[]string{"-x"}, "x", `in command "test foo": flag provided but not defined: -x`},
+		{[]string{"--n=abc"}, "n", `in command "test foo": invalid value "abc" for flag -n: parse error`},
+		{[]string{"-n"}, "n", `in command "test foo": flag needs an argument: -n`},
+	}
+
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		r := RunnerOf(cmds, Config{
+			Args:                append([]string{"./someapp", "test", "foo"}, tc.args...),
+			Output:              buf,
+			UsageErrorsToStderr: true,
+		})
+		err := r.Run()
+
+		var parseErr ErrFlagParse
+		if !errors.As(err, &parseErr) {
+			t.Fatal(err)
+		}
+		mustEqual(t, err.Error(), tc.wantErr)
+		mustEqual(t, parseErr.Path, "test foo")
+		mustEqual(t, parseErr.Flag, tc.flag)
+		mustEqual(t, r.exitCode(err), 2)
+		mustEqual(t, buf.String(), "")
+	}
+
+	fset := flag.NewFlagSet("foo", flag.ContinueOnError)
+	mustEqual(t, ParseFlags(context.Background(), fset, []string{"-h"}), flag.ErrHelp)
+	failIfErr(t, ParseFlags(context.Background(), fset, []string{"arg"}))
+	mustEqual(t, fset.Args(), []string{"arg"})
+}
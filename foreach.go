@@ -0,0 +1,99 @@
+package acmd
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ForEach calls fn for each index from 0 to n-1 with at most concurrency calls at a time.
+// If concurrency < 1, runtime.GOMAXPROCS(0) is used.
+// Failed items don't stop the others, their errors are collected into *ForEachError.
+// After ctx is done no new items are started and ctx.Err() is returned (unless items failed).
+// Each finished item is reported with Progress, ex: "3/10".
+func ForEach(ctx context.Context, n, concurrency int, fn func(ctx context.Context, i int) error) error {
+	if concurrency < 1 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+		errs = map[int]error{}
+		sem  = make(chan struct{}, concurrency)
+	)
+
+loop:
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			break loop
+		case sem <- struct{}{}:
+		}
+		// select picks randomly if both are ready, so ctx is checked again.
+		if ctx.Err() != nil {
+			<-sem
+			break loop
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := fn(ctx, i)
+
+			mu.Lock()
+			if err != nil {
+				errs[i] = err
+			}
+			done++
+			msg := fmt.Sprintf("%d/%d", done, n)
+			mu.Unlock()
+			Progress(ctx, msg)
+		}(i)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return &ForEachError{Total: n, Errs: errs}
+	}
+	return ctx.Err()
+}
+
+// ForEachError is returned by ForEach when some of the items failed.
+type ForEachError struct {
+	Total int           // number of items
+	Errs  map[int]error // errors by item index
+}
+
+func (e *ForEachError) Error() string {
+	idxs := e.indexes()
+	msgs := make([]string, len(idxs))
+	for i, idx := range idxs {
+		msgs[i] = fmt.Sprintf("item %d: %v", idx, e.Errs[idx])
+	}
+	return fmt.Sprintf("%d of %d items failed: %s", len(idxs), e.Total, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the error of the item with the lowest index.
+func (e *ForEachError) Unwrap() error {
+	idxs := e.indexes()
+	if len(idxs) == 0 {
+		return nil
+	}
+	return e.Errs[idxs[0]]
+}
+
+func (e *ForEachError) indexes() []int {
+	idxs := make([]int, 0, len(e.Errs))
+	for idx := range e.Errs {
+		idxs = append(idxs, idx)
+	}
+	sort.Ints(idxs)
+	return idxs
+}
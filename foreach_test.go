@@ -0,0 +1,92 @@
+package acmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestForEach(t *testing.T) {
+	var running, maxRunning, calls int32
+	errBoom := errors.New("boom")
+
+	err := ForEach(context.Background(), 10, 3, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		cur := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if cur <= max || atomic.CompareAndSwapInt32(&maxRunning, max, cur) {
+				break
+			}
+		}
+
+		if i == 7 || i == 2 {
+			return errBoom
+		}
+		return nil
+	})
+
+	var feErr *ForEachError
+	if !errors.As(err, &feErr) {
+		t.Fatal(err)
+	}
+	mustEqual(t, err.Error(), "2 of 10 items failed: item 2: boom; item 7: boom")
+	mustEqual(t, errors.Is(err, errBoom), true)
+	mustEqual(t, atomic.LoadInt32(&calls), int32(10))
+	if maxRunning > 3 {
+		t.Fatalf("got %d concurrent calls", maxRunning)
+	}
+
+	failIfErr(t, ForEach(context.Background(), 0, 0, nil))
+}
+
+func TestForEach_cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int32
+
+	err := ForEach(ctx, 100, 1, func(ctx context.Context, i int) error {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+	// no new items are started after cancel.
+	mustEqual(t, atomic.LoadInt32(&calls), int32(2))
+
+	calls = 0
+	err = ForEach(ctx, 100, 10, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+	mustEqual(t, err, context.Canceled)
+	mustEqual(t, atomic.LoadInt32(&calls), int32(0))
+}
+
+func TestForEach_progress(t *testing.T) {
+	events := &bytes.Buffer{}
+	cmds := []Command{{
+		Name: "sync",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			return ForEach(ctx, 2, 1, func(ctx context.Context, i int) error { return nil })
+		},
+	}}
+	r := RunnerOf(cmds, Config{
+		Args:        []string{"./someapp", "sync"},
+		Output:      io.Discard,
+		EventOutput: events,
+	})
+	failIfErr(t, r.Run())
+
+	out := events.String()
+	if !strings.Contains(out, `"message":"1/2"`) || !strings.Contains(out, `"message":"2/2"`) {
+		t.Fatal(out)
+	}
+}
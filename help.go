@@ -0,0 +1,142 @@
+package acmd
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// printHelpFor a help topic or a command, ex: `help auth` or `help time next`.
+func (r *Runner) printHelpFor(args []string) error {
+	if len(args) == 1 {
+		for _, topic := range r.cfg.HelpTopics {
+			if topic.Name == args[0] {
+				content, err := readDoc(&r.cfg, topic.Content, topic.ContentFile)
+				if err != nil {
+					return fmt.Errorf("help topic %q: %w", topic.Name, err)
+				}
+				fmt.Fprintf(r.cfg.Output, "%s\n\n%s\n\n", topic.Title, strings.TrimSpace(content))
+				return nil
+			}
+		}
+	}
+
+	var names []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+		names = append(names, arg)
+	}
+
+	path, cmd, err := lookupCommand(&r.cfg, r.cmds, names)
+	if err != nil {
+		return err
+	}
+	if cmd == nil {
+		return fmt.Errorf("unknown help topic or command %q", strings.Join(names, " "))
+	}
+	return r.printCommandHelp(path, cmd)
+}
+
+// lookupCommand by names (or aliases), returns nil if not found.
+// Returns an error for commands which cannot be run, like findCmd does.
+func lookupCommand(cfg *Config, cmds []Command, names []string) ([]string, *Command, error) {
+	var path []string
+	var found *Command
+	for _, name := range names {
+		found = nil
+		for i := range cmds {
+			if cmds[i].Name == name || (cmds[i].Alias != "" && cmds[i].Alias == name) {
+				found = &cmds[i]
+				break
+			}
+		}
+		if found == nil {
+			return nil, nil, nil
+		}
+		path = append(path, found.Name)
+		if err := found.checkAvailable(cfg, path); err != nil {
+			return nil, nil, err
+		}
+		if err := found.loadSubcommands(cfg, path); err != nil {
+			return nil, nil, err
+		}
+		cmds = found.Subcommands
+	}
+	return path, found, nil
+}
+
+// printCommandHelp prints usage of subcommands for a parent command
+// or the description and flags for a command without subcommands.
+func (r *Runner) printCommandHelp(path []string, cmd *Command) error {
+	description, err := readDoc(&r.cfg, cmd.Description, cmd.DescriptionFile)
+	if err != nil {
+		return fmt.Errorf("command %q: %w", strings.Join(path, " "), err)
+	}
+
+	if len(cmd.Subcommands) != 0 {
+		cfg := scopedConfig(r.cfg, path, cmd)
+		cfg.AppDescription = description
+		r.printUsage(cfg, cmd.Subcommands)
+		return nil
+	}
+
+	cfg := r.cfg
+	cfg.AppName = r.cfg.AppName + " " + strings.Join(path, " ")
+
+	w := cfg.Output
+	if description != "" {
+		fmt.Fprintf(w, "%s\n\n", description)
+	}
+	fmt.Fprintf(w, "Usage:\n\n    %s [arguments...]\n\n", cfg.AppName)
+	if cmd.Alias != "" {
+		fmt.Fprintf(w, "Alias: %s\n\n", cmd.Alias)
+	}
+
+	if cmd.FlagSet != nil {
+		fmt.Fprintf(w, "Flags:\n\n")
+		fset := cmd.FlagSet.Flags()
+		old := fset.Output()
+		fset.SetOutput(w)
+		fset.PrintDefaults()
+		fset.SetOutput(old)
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// readDoc returns the text or the file content from Config.DocsFS if file is set.
+func readDoc(cfg *Config, text, file string) (string, error) {
+	if file == "" {
+		return text, nil
+	}
+	if cfg.DocsFS == nil {
+		return "", fmt.Errorf("cannot read %q: Config.DocsFS is nil", file)
+	}
+	b, err := fs.ReadFile(cfg.DocsFS, file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// scopedConfig to print usage of the parent command subcommands as if it was an app.
+func scopedConfig(cfg Config, path []string, cmd *Command) Config {
+	cfg.AppName = cfg.AppName + " " + strings.Join(path, " ")
+	cfg.AppDescription = cmd.Description
+	cfg.PostDescription = ""
+	cfg.Version = ""
+	cfg.HelpTopics = nil
+	return cfg
+}
+
+// printIndex of the visible commands for shell integrations, one command per line:
+// name, full path and description separated by tabs.
+func (r *Runner) printIndex() {
+	walkVisible(&r.cfg, r.cmds, nil, func(path []string, cmd *Command) bool {
+		desc := strings.ReplaceAll(cmd.Description, "\t", " ")
+		fmt.Fprintf(r.cfg.Output, "%s\t%s\t%s\n", cmd.Name, strings.Join(path, " "), desc)
+		return true
+	})
+}
@@ -0,0 +1,121 @@
+package acmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyEntry is a single line of the history file.
+type historyEntry struct {
+	Time time.Time `json:"time"`
+	Args []string  `json:"args"`
+	Code int       `json:"code"`
+}
+
+func (r *Runner) historyCmd() Command {
+	return Command{
+		Name:        "history",
+		Description: "shows executed commands, use `history rerun N` to run again",
+		ExecFunc: builtinFunc(func(ctx context.Context, args []string) error {
+			entries, err := r.readHistory()
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 0 {
+				for i, e := range entries {
+					fmt.Fprintf(r.cfg.Output, "%5d  %s  %3d  %s\n",
+						i+1, e.Time.Format("2006-01-02 15:04:05"), e.Code, strings.Join(e.Args, " "))
+				}
+				return nil
+			}
+
+			if args[0] != "rerun" || len(args) != 2 {
+				return errors.New("usage: history [rerun N]")
+			}
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 || n > len(entries) {
+				return fmt.Errorf("no history entry %q", args[1])
+			}
+			return r.runArgs(ctx, entries[n-1].Args)
+		}),
+	}
+}
+
+func (r *Runner) historyFile() (string, error) {
+	dir, err := appDir(userCacheDir, r.cfg.AppName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history"), nil
+}
+
+// recordHistory appends the executed args and exit code to the history file.
+// Errors are ignored, history must not break the command.
+func (r *Runner) recordHistory(args []string, runErr error) {
+	if r.cfg.HistoryRedact != nil {
+		args = r.cfg.HistoryRedact(append([]string(nil), args...))
+		if args == nil {
+			return
+		}
+	}
+
+	file, err := r.historyFile()
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	entry := historyEntry{Time: time.Now(), Args: args, Code: r.exitCode(runErr)}
+	json.NewEncoder(f).Encode(entry)
+}
+
+func (r *Runner) readHistory() ([]historyEntry, error) {
+	file, err := r.historyFile()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(file)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	defer f.Close()
+
+	// bufio.Scanner isn't used as lines with long args can exceed its token limit.
+	var entries []historyEntry
+	br := bufio.NewReader(f)
+	for {
+		line, err := br.ReadBytes('\n')
+		var e historyEntry
+		if len(line) != 0 && json.Unmarshal(line, &e) == nil { // skip broken lines
+			entries = append(entries, e)
+		}
+		switch {
+		case errors.Is(err, io.EOF):
+			return entries, nil
+		case err != nil:
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package acmd
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+type noInputKey struct{}
+
+// NoInput reports whether the app is run with --no-input flag.
+// Commands must not prompt the user then, even if Input is a terminal.
+func NoInput(ctx context.Context) bool {
+	noInput, _ := ctx.Value(noInputKey{}).(bool)
+	return noInput
+}
+
+// ReadInput reads the whole file by path or stdin if path is "-".
+// Input of the Runner is used as stdin if ctx is from the Runner, os.Stdin otherwise.
+func ReadInput(ctx context.Context, path string) ([]byte, error) {
+	if path != "-" {
+		return os.ReadFile(path)
+	}
+	in, _ := promptIO(ctx)
+	return io.ReadAll(in)
+}
+
+// RawArgs returns the args as the app was run with, including the program name,
+// before global flags and aliases are handled. Useful to re-exec the app with the same args.
+// Returns os.Args if ctx is not the one passed to the command by the Runner.
+func RawArgs(ctx context.Context) []string {
+	if inv := invocationFrom(ctx); inv != nil {
+		return append([]string(nil), inv.runner.rawArgs...)
+	}
+	return append([]string(nil), os.Args...)
+}
+
+// IsTerminal reports whether v is a terminal (character device), ex: os.Stdin.
+// Returns false for everything except *os.File.
+func IsTerminal(v interface{}) bool {
+	f, ok := v.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+// Stream is an output of the app with a knowledge whether it is a terminal.
+type Stream struct {
+	io.Writer
+	tty bool
+}
+
+// IsTTY reports whether the stream is a terminal.
+// Usually commands print human-readable output to a terminal
+// and machine-readable output (ex: JSON) when it is redirected to a file or a pipe.
+func (s Stream) IsTTY() bool {
+	return s.tty
+}
+
+// Stdout returns the Output of the Runner if ctx is from the Runner, os.Stdout otherwise.
+func Stdout(ctx context.Context) Stream {
+	if inv := invocationFrom(ctx); inv != nil {
+		return Stream{Writer: inv.runner.cfg.Output, tty: inv.runner.outputTTY}
+	}
+	return Stream{Writer: os.Stdout, tty: IsTerminal(os.Stdout)}
+}
+
+// Stderr returns the ErrOutput of the Runner if ctx is from the Runner, os.Stderr otherwise.
+func Stderr(ctx context.Context) Stream {
+	if inv := invocationFrom(ctx); inv != nil {
+		return Stream{Writer: inv.runner.cfg.ErrOutput, tty: inv.runner.errOutputTTY}
+	}
+	return Stream{Writer: os.Stderr, tty: IsTerminal(os.Stderr)}
+}
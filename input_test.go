@@ -0,0 +1,95 @@
+package acmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadInput(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "input")
+	failIfErr(t, os.WriteFile(name, []byte("from file"), 0o600))
+
+	var fromFile, fromStdin []byte
+	cmds := []Command{{
+		Name: "apply",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			var err error
+			fromFile, err = ReadInput(ctx, name)
+			failIfErr(t, err)
+			fromStdin, err = ReadInput(ctx, "-")
+			return err
+		},
+	}}
+
+	r := RunnerOf(cmds, Config{
+		Args:   []string{"./someapp", "apply"},
+		Input:  strings.NewReader("from stdin"),
+		Output: io.Discard,
+	})
+	failIfErr(t, r.Run())
+
+	mustEqual(t, string(fromFile), "from file")
+	mustEqual(t, string(fromStdin), "from stdin")
+
+	_, err := ReadInput(context.Background(), filepath.Join(t.TempDir(), "missing"))
+	if !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func TestRawArgs(t *testing.T) {
+	var got []string
+	cmds := []Command{{
+		Name:  "status",
+		Alias: "st",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			got = RawArgs(ctx)
+			got[0] = "changed"
+			return nil
+		},
+	}}
+
+	args := []string{"./myapp", "--no-pager", "st", "-v"}
+	r := RunnerOf(cmds, Config{Args: args, Output: io.Discard})
+	failIfErr(t, r.Run())
+	mustEqual(t, got, []string{"changed", "--no-pager", "st", "-v"})
+	mustEqual(t, args[0], "./myapp")
+
+	mustEqual(t, RawArgs(context.Background()), os.Args)
+}
+
+func TestIsTerminal(t *testing.T) {
+	mustEqual(t, IsTerminal(strings.NewReader("")), false)
+	mustEqual(t, IsTerminal(nil), false)
+
+	tmp, err := os.CreateTemp(t.TempDir(), "file")
+	failIfErr(t, err)
+	defer tmp.Close()
+	mustEqual(t, IsTerminal(tmp), false)
+}
+
+func TestStdout(t *testing.T) {
+	var isTTY bool
+	cmds := []Command{{
+		Name: "status",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			out := Stdout(ctx)
+			isTTY = out.IsTTY()
+			_, err := io.WriteString(out, "ok")
+			return err
+		},
+	}}
+
+	buf := &strings.Builder{}
+	r := RunnerOf(cmds, Config{Args: []string{"./myapp", "status"}, Output: buf})
+	failIfErr(t, r.Run())
+	mustEqual(t, buf.String(), "ok")
+	mustEqual(t, isTTY, false)
+
+	mustEqual(t, Stdout(context.Background()).Writer, io.Writer(os.Stdout))
+	mustEqual(t, Stderr(context.Background()).Writer, io.Writer(os.Stderr))
+}
@@ -0,0 +1,24 @@
+package acmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// loadSubcommands calls SubcommandsFunc once and keeps the validated result in Subcommands.
+func (cmd *Command) loadSubcommands(cfg *Config, path []string) error {
+	if cmd.SubcommandsFunc == nil {
+		return nil
+	}
+
+	cmds := cmd.SubcommandsFunc()
+	if len(cmds) == 0 {
+		return fmt.Errorf("command %q has no subcommands", strings.Join(path, " "))
+	}
+	if err := validateSubcommands(cfg, path, cmds); err != nil {
+		return err
+	}
+	cmd.Subcommands = cmds
+	cmd.SubcommandsFunc = nil
+	return nil
+}
@@ -1,14 +1,18 @@
 package acmd
 
 // strDistance between 2 strings using Levenshtein distance algorithm.
-func strDistance(a, b string) int {
+// Distance is counted in runes, not bytes.
+func strDistance(s1, s2 string) int {
+	if s1 == s2 {
+		return 0
+	}
+	a, b := []rune(s1), []rune(s2)
+
 	switch {
-	case a == "":
+	case len(a) == 0:
 		return len(b)
-	case b == "":
+	case len(b) == 0:
 		return len(a)
-	case a == b:
-		return 0
 	}
 
 	if len(a) > len(b) {
@@ -35,6 +39,81 @@ func strDistance(a, b string) int {
 	return x[lenA]
 }
 
+// strDistanceMax is like strDistance but stops when distance exceeds maxDist.
+// Only a band of width 2*maxDist+1 is computed, so it's cheap for long strings.
+// Returns maxDist+1 if the distance is greater than maxDist.
+func strDistanceMax(s1, s2 string, maxDist int) int {
+	if s1 == s2 {
+		return 0
+	}
+	a, b := []rune(s1), []rune(s2)
+
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	lenA, lenB := len(a), len(b)
+	inf := maxDist + 1
+
+	switch {
+	case lenB-lenA > maxDist:
+		return inf
+	case lenA == 0:
+		return lenB
+	}
+
+	rows := make([]int, 2*(lenA+1))
+	prev, curr := rows[:lenA+1], rows[lenA+1:]
+	for j := range prev {
+		prev[j] = j
+		if j > maxDist {
+			prev[j] = inf
+		}
+	}
+
+	for i := 1; i <= lenB; i++ {
+		lo, hi := 1, lenA
+		if i-maxDist > lo {
+			lo = i - maxDist
+		}
+		if i+maxDist < hi {
+			hi = i + maxDist
+		}
+
+		if lo == 1 {
+			curr[0] = i
+			if i > maxDist {
+				curr[0] = inf
+			}
+		} else {
+			curr[lo-1] = inf
+		}
+
+		rowMin := curr[lo-1]
+		for j := lo; j <= hi; j++ {
+			current := prev[j-1] // match
+			if b[i-1] != a[j-1] {
+				current = min3(prev[j-1]+1, curr[j-1]+1, prev[j]+1)
+			}
+			if current > inf {
+				current = inf
+			}
+			curr[j] = current
+			if current < rowMin {
+				rowMin = current
+			}
+		}
+		if hi < lenA {
+			curr[hi+1] = inf
+		}
+
+		if rowMin > maxDist {
+			return inf
+		}
+		prev, curr = curr, prev
+	}
+	return prev[lenA]
+}
+
 func min3(a, b, c int) int {
 	if a < b {
 		if a < c {
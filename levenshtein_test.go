@@ -1,6 +1,9 @@
 package acmd
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func Test_strDistance(t *testing.T) {
 	testCases := []struct {
@@ -19,6 +22,9 @@ func Test_strDistance(t *testing.T) {
 		{"kitten", "sitting", 3},
 		{"distance", "difference", 5},
 		{"resume and cafe", "resumes and cafes", 2},
+		{"привет", "привет", 0},
+		{"привет", "превет", 1},
+		{"日本", "日本語", 1},
 	}
 
 	for _, tc := range testCases {
@@ -26,5 +32,58 @@ func Test_strDistance(t *testing.T) {
 		if dist != tc.want {
 			t.Errorf("for (%q , %q) want %d, got %d", tc.a, tc.b, tc.want, dist)
 		}
+
+		for maxDist := 0; maxDist <= 6; maxDist++ {
+			want := tc.want
+			if want > maxDist {
+				want = maxDist + 1
+			}
+			dist := strDistanceMax(tc.a, tc.b, maxDist)
+			if dist != want {
+				t.Errorf("for (%q , %q, %d) want %d, got %d", tc.a, tc.b, maxDist, want, dist)
+			}
+		}
 	}
 }
+
+func Test_strDistanceMaxFuzzy(t *testing.T) {
+	words := []string{"", "a", "ab", "abc", "bca", "help", "hepl", "version", "verZion", "status", "stats", "kitten", "sitting"}
+	for _, a := range words {
+		for _, b := range words {
+			full := strDistance(a, b)
+			for maxDist := 0; maxDist <= 3; maxDist++ {
+				want := full
+				if want > maxDist {
+					want = maxDist + 1
+				}
+				if got := strDistanceMax(a, b, maxDist); got != want {
+					t.Errorf("for (%q , %q, %d) want %d, got %d", a, b, maxDist, want, got)
+				}
+			}
+		}
+	}
+}
+
+func Benchmark_suggestCommand(b *testing.B) {
+	cmds := make([]Command, 500)
+	for i := range cmds {
+		cmds[i] = Command{Name: fmt.Sprintf("some-long-command-name-%d", i)}
+	}
+	const input = "some-long-commend-name-42x"
+
+	b.Run("strDistance", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, c := range cmds {
+				_ = strDistance(input, c.Name)
+			}
+		}
+	})
+
+	b.Run("strDistanceMax", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = suggestCommand(input, cmds)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package acmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// teeToLogFile makes Output and ErrOutput to be copied into LogFile.
+// The file is closed when Run returns, see reopenLogFile for Runner.Exit.
+// Next runs of the same Runner reuse the outputs and reopen the file.
+func (r *Runner) teeToLogFile() error {
+	if r.logFile == nil {
+		w := &logWriter{name: r.cfg.LogFile}
+		if err := w.open(r.cfg.LogMaxSize); err != nil {
+			return fmt.Errorf("log file: %w", err)
+		}
+		r.logFile = w
+
+		r.outputMu.Lock()
+		r.cfg.Output = io.MultiWriter(r.cfg.Output, r.logFile)
+		r.cfg.ErrOutput = io.MultiWriter(r.cfg.ErrOutput, r.logFile)
+		r.outputMu.Unlock()
+	} else if err := r.logFile.open(r.cfg.LogMaxSize); err != nil {
+		return fmt.Errorf("log file: %w", err)
+	}
+
+	fmt.Fprintf(r.logFile, "=== %s %s %s\n",
+		time.Now().Format(time.RFC3339), filepath.Base(r.cfg.AppName), strings.Join(r.args, " "))
+	return nil
+}
+
+// reopenLogFile closed after Run, so the error printed by Runner.Exit and finalizers are logged too.
+// Returned func closes the file again.
+func (r *Runner) reopenLogFile() func() {
+	if r.logFile == nil {
+		return func() {}
+	}
+	// the error is still printed to ErrOutput, nothing to do if the file cannot be opened.
+	_ = r.logFile.open(0)
+	return func() { r.logFile.Close() }
+}
+
+// logWriter is a log file shared by Output and ErrOutput.
+// Writes when the file is closed are dropped, so the outputs can be used after Run returns.
+type logWriter struct {
+	mu   sync.Mutex
+	name string
+	f    *os.File
+}
+
+// open the file if it's closed, see openLogFile.
+func (w *logWriter) open(maxSize int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f != nil {
+		return nil
+	}
+	f, err := openLogFile(w.name, maxSize)
+	if err != nil {
+		return err
+	}
+	w.f = f
+	return nil
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return len(p), nil
+	}
+	return w.f.Write(p)
+}
+
+func (w *logWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+// openLogFile for appending, rotates it first if it exceeds maxSize.
+func openLogFile(name string, maxSize int64) (*os.File, error) {
+	if maxSize > 0 {
+		if stat, err := os.Stat(name); err == nil && stat.Size() >= maxSize {
+			if err := os.Rename(name, name+".1"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+}
@@ -0,0 +1,108 @@
+package acmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunnerLogFile(t *testing.T) {
+	doExitOld := func(code int) {}
+	defer func() { doExit = doExitOld }()
+	doExitOld, doExit = doExit, doExitOld
+
+	logFile := filepath.Join(t.TempDir(), "app.log")
+
+	run := func(args ...string) string {
+		buf := &bytes.Buffer{}
+		var r *Runner
+		cmds := []Command{
+			{
+				Name: "hello",
+				ExecFunc: func(ctx context.Context, args []string) error {
+					fmt.Fprintf(r.cfg.Output, "hello %s\n", strings.Join(args, " "))
+					return nil
+				},
+			},
+			{
+				Name: "fail",
+				ExecFunc: func(ctx context.Context, args []string) error {
+					return errors.New("boom")
+				},
+			},
+		}
+		r = RunnerOf(cmds, Config{
+			Args:        append([]string{"./someapp"}, args...),
+			AppName:     "myapp",
+			Output:      buf,
+			ErrOutput:   buf,
+			LogMaxSize:  100,
+			LogFileFlag: true,
+		})
+		r.Exit(r.Run())
+		if r.logFile != nil && r.logFile.f != nil {
+			t.Fatal("log file must be closed")
+		}
+		return buf.String()
+	}
+
+	out := run("--log-file="+logFile, "hello", "world")
+	mustEqual(t, out, "hello world\n")
+
+	data, err := os.ReadFile(logFile)
+	failIfErr(t, err)
+	if s := string(data); !strings.Contains(s, " myapp hello world\nhello world\n") {
+		t.Fatal(s)
+	}
+
+	run("--log-file="+logFile, "hello", strings.Repeat("x", 100))
+	run("--log-file="+logFile, "hello", "again")
+
+	if _, err := os.Stat(logFile + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(logFile)
+	failIfErr(t, err)
+	if s := string(data); !strings.Contains(s, "hello again") || strings.Contains(s, "hello world") {
+		t.Fatal(s)
+	}
+
+	out = run("--log-file="+logFile, "fail")
+	mustEqual(t, out, "myapp: boom\n")
+
+	data, err = os.ReadFile(logFile)
+	failIfErr(t, err)
+	if s := string(data); !strings.HasSuffix(s, " myapp fail\nmyapp: boom\n") {
+		t.Fatal(s)
+	}
+}
+
+func TestRunnerLogFileClosedAfterRun(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	cmds := []Command{{Name: "foo", ExecFunc: nopFunc}}
+
+	r := RunnerOf(cmds, Config{
+		Args:    []string{"./someapp", "foo"},
+		Output:  io.Discard,
+		LogFile: logFile,
+	})
+	failIfErr(t, r.Run())
+	if r.logFile == nil || r.logFile.f != nil {
+		t.Fatal("log file must be closed")
+	}
+
+	r = RunnerOf(cmds, Config{
+		Args:   []string{"./someapp", "--log-file=" + logFile, "foo"},
+		Output: io.Discard,
+		Usage:  nopUsage,
+	})
+	if err := r.Run(); err == nil || !strings.Contains(err.Error(), "no such command") {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,15 @@
+package acmd
+
+// Mount returns a command named prefix with cmds as subcommands, so an existing app
+// can be embedded into a bigger one, ex: `bigtool subtool ...`.
+// cmds are the ones the embedded app passes to RunnerOf, no Runner is created for them,
+// so nothing is parsed or loaded until the outer Runner runs the commands.
+// Config of the outer Runner (Output, Context, etc) is used to run the commands
+// and help shows them with the outer AppName and full paths, ex: `bigtool subtool lint`.
+func Mount(prefix, description string, cmds []Command) Command {
+	return Command{
+		Name:        prefix,
+		Description: description,
+		Subcommands: append([]Command(nil), cmds...),
+	}
+}
@@ -0,0 +1,61 @@
+package acmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// printUsage via pager if it's enabled and possible, otherwise directly to the Output.
+func (r *Runner) printUsage(cfg Config, cmds []Command) {
+	out := cfg.Output
+	if !cfg.UsePager || r.noPager || !IsTerminal(out) {
+		cfg.Usage(cfg, cmds)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	cfg.Output = buf
+	cfg.Usage(cfg, cmds)
+
+	if err := runPager(out, buf); err != nil {
+		out.Write(buf.Bytes())
+	}
+}
+
+// printPaged content via pager if it's enabled and possible, otherwise directly to out.
+func (r *Runner) printPaged(out io.Writer, content string) {
+	if !r.cfg.UsePager || r.noPager || !IsTerminal(out) {
+		io.WriteString(out, content)
+		return
+	}
+	if err := runPager(out, strings.NewReader(content)); err != nil {
+		io.WriteString(out, content)
+	}
+}
+
+// runPager writes content via $PAGER (or less) to w.
+// Like git, less is instructed to quit if content fits one screen.
+func runPager(w io.Writer, content io.Reader) error {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less"
+	}
+	args := strings.Fields(pager)
+	if len(args) == 0 || args[0] == "cat" {
+		_, err := io.Copy(w, content)
+		return err
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = content
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	return cmd.Run()
+}
@@ -0,0 +1,76 @@
+package acmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// heldPIDFiles are pid files taken outside of the Runner, held until the process exits.
+var (
+	heldPIDFilesMu sync.Mutex
+	heldPIDFiles   []*os.File
+)
+
+// PIDFile writes the current process id to the file at path and locks it,
+// so only one instance of the command (ex: `serve`) can run at a time.
+// Returns ErrAlreadyRunning if the file is held by another running process,
+// a stale file left by a crashed process is overwritten.
+// The file is removed when the command run with ctx finishes.
+// If ctx is not the one passed to the command by the Runner, the file is held until the process exits.
+//
+// Locking is done with flock on Linux, macOS and BSDs. On other platforms (ex: Windows)
+// it is best-effort: the file is created exclusively and a file with a pid of a process
+// which is not running is taken over, which is racy for concurrent takeovers.
+func PIDFile(ctx context.Context, path string) error {
+	f, ok, err := openLocked(path, 0o644)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		b, _ := os.ReadFile(path)
+		return fmt.Errorf("pid file %q: %w with pid %s", path, ErrAlreadyRunning, strings.TrimSpace(string(b)))
+	}
+
+	if err := writePID(f); err != nil {
+		releaseLocked(f)
+		return err
+	}
+
+	inv := invocationFrom(ctx)
+	if inv == nil {
+		heldPIDFilesMu.Lock()
+		heldPIDFiles = append(heldPIDFiles, f)
+		heldPIDFilesMu.Unlock()
+		return nil
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.pidFiles = append(inv.pidFiles, f)
+	return nil
+}
+
+func writePID(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// removePIDFiles taken by PIDFile, if any.
+func (inv *invocation) removePIDFiles() {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	for _, f := range inv.pidFiles {
+		releaseLocked(f)
+	}
+	inv.pidFiles = nil
+}
@@ -0,0 +1,75 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package acmd
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// openLocked is best-effort on this platform as there is no advisory locking:
+// the file is created exclusively and is held while it exists. A file with a pid
+// of a process which is not running (see processAlive) is stale and is taken over,
+// but two processes taking over the same stale file at once can both succeed.
+func openLocked(path string, perm os.FileMode) (*os.File, bool, error) {
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if err == nil {
+			return f, true, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, false, err
+		}
+
+		if pid := readPID(path); pid != 0 {
+			if processAlive(pid) {
+				return nil, false, nil
+			}
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, false, err
+		}
+	}
+	return nil, false, nil
+}
+
+// releaseLocked file taken by openLocked. The file is closed first,
+// as an open file cannot be removed on some platforms (ex: Windows).
+func releaseLocked(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// processAlive reports whether the process with pid is running.
+// os.FindProcess fails for such process only on Windows, on other platforms procfs is checked
+// (ex: Solaris, AIX and Plan 9). Without procfs the process is assumed to be running.
+func processAlive(pid int) bool {
+	if runtime.GOOS == "windows" {
+		p, err := os.FindProcess(pid)
+		if err != nil {
+			return false
+		}
+		p.Release()
+		return true
+	}
+
+	if _, err := os.Stat("/proc"); err != nil {
+		return true
+	}
+	_, err := os.Stat("/proc/" + strconv.Itoa(pid))
+	return err == nil
+}
+
+// readPID from the pid file, 0 if the file is empty or broken.
+func readPID(path string) int {
+	b, _ := os.ReadFile(path)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
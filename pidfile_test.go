@@ -0,0 +1,68 @@
+package acmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "serve.pid")
+
+	var content string
+	var nestedErr error
+	cmds := []Command{{
+		Name:    "serve",
+		PIDFile: path,
+		ExecFunc: func(ctx context.Context, args []string) error {
+			b, err := os.ReadFile(path)
+			content = string(b)
+			nestedErr = PIDFile(ctx, path)
+			return err
+		},
+	}}
+	r := RunnerOf(cmds, Config{
+		Args:   []string{"./myapp", "serve"},
+		Output: io.Discard,
+	})
+	failIfErr(t, r.Run())
+
+	mustEqual(t, content, strconv.Itoa(os.Getpid())+"\n")
+	if !errors.Is(nestedErr, ErrAlreadyRunning) {
+		t.Fatal(nestedErr)
+	}
+	if !strings.HasSuffix(nestedErr.Error(), "already running with pid "+strconv.Itoa(os.Getpid())) {
+		t.Fatal(nestedErr)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("pid file must be removed")
+	}
+}
+
+func TestPIDFile_stale(t *testing.T) {
+	switch runtime.GOOS {
+	case "js", "wasip1":
+		t.Skip("running processes cannot be checked, so stale pid files are not detected")
+	}
+
+	path := filepath.Join(t.TempDir(), "serve.pid")
+	failIfErr(t, os.WriteFile(path, []byte("999999\n"), 0o644))
+
+	cmds := []Command{{
+		Name: "serve",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			return PIDFile(ctx, path)
+		},
+	}}
+	r := RunnerOf(cmds, Config{
+		Args:   []string{"./myapp", "serve"},
+		Output: io.Discard,
+	})
+	failIfErr(t, r.Run())
+}
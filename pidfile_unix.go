@@ -0,0 +1,44 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package acmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// openLocked opens (or creates) the file at path with an exclusive advisory lock,
+// reports false if it is held by another process (or another open file of this process).
+// The lock is released by the OS when the process exits, so a stale file is just locked again.
+func openLocked(path string, perm os.FileMode) (*os.File, bool, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, perm)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			f.Close()
+			return nil, false, nil
+		}
+
+		// the file could be removed by releaseLocked of the previous holder (and created again by
+		// another process) between open and flock, the lock is valid only if the file is still at path.
+		stat, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, false, err
+		}
+		if cur, err := os.Stat(path); err == nil && os.SameFile(stat, cur) {
+			return f, true, nil
+		}
+		f.Close()
+	}
+}
+
+// releaseLocked file taken by openLocked. The file is removed before the lock is released,
+// so the next process doesn't lock the file which is about to be removed.
+func releaseLocked(f *os.File) {
+	os.Remove(f.Name())
+	f.Close()
+}
@@ -0,0 +1,41 @@
+package acmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FindProjectRoot walks up from the current directory to find a dir with any of the markers.
+// If markers are not set, .git and go.mod are used.
+// Returns ErrNoProjectRoot (wrapped) if nothing is found.
+func FindProjectRoot(ctx context.Context, markers ...string) (string, error) {
+	if len(markers) == 0 {
+		markers = []string{".git", "go.mod"}
+	}
+
+	dir, err := getwd()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		for _, marker := range markers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return dir, nil
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("%w (looking for %s)", ErrNoProjectRoot, strings.Join(markers, ", "))
+		}
+		dir = parent
+	}
+}
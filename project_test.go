@@ -0,0 +1,53 @@
+package acmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindProjectRoot(t *testing.T) {
+	defer func(f func() (string, error)) { getwd = f }(getwd)
+
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b", "c")
+	failIfErr(t, os.MkdirAll(nested, 0o755))
+	failIfErr(t, os.WriteFile(filepath.Join(root, "project.toml"), nil, 0o644))
+	failIfErr(t, os.Mkdir(filepath.Join(root, "a", ".git"), 0o755))
+
+	getwd = func() (string, error) { return nested, nil }
+
+	dir, err := FindProjectRoot(context.Background())
+	failIfErr(t, err)
+	mustEqual(t, dir, filepath.Join(root, "a"))
+
+	dir, err = FindProjectRoot(context.Background(), "project.toml")
+	failIfErr(t, err)
+	mustEqual(t, dir, root)
+
+	_, err = FindProjectRoot(context.Background(), "no-such-marker-for-sure")
+	if !errors.Is(err, ErrNoProjectRoot) {
+		t.Fatal(err)
+	}
+
+	cmds := []Command{{Name: "build", NeedsProject: true, ExecFunc: nopFunc}}
+	err = RunnerOf(cmds, Config{
+		Args:           []string{"./someapp", "build"},
+		Output:         io.Discard,
+		ProjectMarkers: []string{"no-such-marker-for-sure"},
+	}).Run()
+	if !errors.Is(err, ErrNoProjectRoot) {
+		t.Fatal(err)
+	}
+	mustEqual(t, err.Error(), `command "build": must be run inside a project (looking for no-such-marker-for-sure)`)
+
+	err = RunnerOf(cmds, Config{
+		Args:           []string{"./someapp", "build"},
+		Output:         io.Discard,
+		ProjectMarkers: []string{"project.toml"},
+	}).Run()
+	failIfErr(t, err)
+}
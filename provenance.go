@@ -0,0 +1,112 @@
+package acmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Provenance of the artifacts produced by the command, see Config.ProvenanceFile.
+type Provenance struct {
+	Time      time.Time            `json:"time"`
+	Command   string               `json:"command"`
+	Args      []string             `json:"args"`
+	GoVersion string               `json:"go_version"`
+	Main      ProvenanceModule     `json:"main"`
+	Deps      []ProvenanceModule   `json:"deps,omitempty"`
+	Artifacts []ProvenanceArtifact `json:"artifacts"`
+}
+
+// ProvenanceModule is a Go module the binary is built with.
+type ProvenanceModule struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Sum     string `json:"sum,omitempty"`
+}
+
+// ProvenanceArtifact is a file produced by the command.
+type ProvenanceArtifact struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// Artifact registers a file produced by the command run with ctx to be recorded in the provenance.
+// The file is hashed after the command succeeds, so it can be registered before it is written.
+// Does nothing if Config.ProvenanceFile is not set or ctx is not the one passed to the command by the Runner.
+func Artifact(ctx context.Context, path string) {
+	inv := invocationFrom(ctx)
+	if inv == nil || inv.runner.cfg.ProvenanceFile == "" {
+		return
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.artifacts = append(inv.artifacts, path)
+}
+
+// writeProvenance of the finished command to the file.
+func (inv *invocation) writeProvenance(file string) error {
+	inv.mu.Lock()
+	artifacts := inv.artifacts
+	inv.mu.Unlock()
+
+	p := Provenance{
+		Time:      time.Now().UTC(),
+		Command:   strings.Join(inv.path, " "),
+		Args:      inv.runner.rawArgs,
+		GoVersion: runtime.Version(),
+		Artifacts: []ProvenanceArtifact{},
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		p.Main = provenanceModule(&info.Main)
+		for _, dep := range info.Deps {
+			p.Deps = append(p.Deps, provenanceModule(dep))
+		}
+	}
+
+	for _, path := range artifacts {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return fmt.Errorf("provenance: %w", err)
+		}
+		p.Artifacts = append(p.Artifacts, ProvenanceArtifact{Path: path, SHA256: sum})
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("provenance: %w", err)
+	}
+	if err := WriteFileAtomic(file, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("provenance: %w", err)
+	}
+	return nil
+}
+
+func provenanceModule(m *debug.Module) ProvenanceModule {
+	if m.Replace != nil {
+		m = m.Replace
+	}
+	return ProvenanceModule{Path: m.Path, Version: m.Version, Sum: m.Sum}
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,65 @@
+package acmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestProvenance(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	provenance := filepath.Join(dir, "provenance.json")
+
+	cmds := []Command{
+		{
+			Name: "build",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				Artifact(ctx, out)
+				return os.WriteFile(out, []byte("hello"), 0o644)
+			},
+		},
+		{
+			Name: "broken",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				Artifact(ctx, filepath.Join(dir, "missing"))
+				return nil
+			},
+		},
+	}
+
+	r := RunnerOf(cmds, Config{
+		Args:           []string{"./myapp", "--no-pager", "build", "-v"},
+		Output:         io.Discard,
+		ProvenanceFile: provenance,
+	})
+	failIfErr(t, r.Run())
+
+	b, err := os.ReadFile(provenance)
+	failIfErr(t, err)
+
+	var p Provenance
+	failIfErr(t, json.Unmarshal(b, &p))
+	mustEqual(t, p.Command, "build")
+	mustEqual(t, p.Args, []string{"./myapp", "--no-pager", "build", "-v"})
+	mustEqual(t, p.GoVersion, runtime.Version())
+	mustEqual(t, p.Artifacts, []ProvenanceArtifact{{
+		Path:   out,
+		SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+	}})
+
+	r = RunnerOf(cmds, Config{
+		Args:           []string{"./myapp", "broken"},
+		Output:         io.Discard,
+		ProvenanceFile: provenance,
+	})
+	failIfOk(t, r.Run())
+
+	// no-op without ProvenanceFile.
+	r = RunnerOf(cmds, Config{Args: []string{"./myapp", "broken"}, Output: io.Discard})
+	failIfErr(t, r.Run())
+}
@@ -0,0 +1,40 @@
+package acmd
+
+import (
+	"context"
+	"fmt"
+)
+
+// CommandProvider provides commands defined elsewhere, ex: fetched from a registry service.
+// See Config.Providers.
+type CommandProvider interface {
+	List(ctx context.Context) ([]Command, error)
+}
+
+type offlineKey struct{}
+
+// IsOffline reports whether the app is run with --offline flag.
+// Providers should use their cached commands (if any) instead of the network then.
+func IsOffline(ctx context.Context) bool {
+	offline, _ := ctx.Value(offlineKey{}).(bool)
+	return offline
+}
+
+// loadProviders and add their commands to the Runner commands.
+func (r *Runner) loadProviders() error {
+	for i, p := range r.cfg.Providers {
+		cmds, err := p.List(r.ctx)
+		if err != nil {
+			return fmt.Errorf("command provider %d: %w", i+1, err)
+		}
+		r.cmds = append(r.cmds[:len(r.cmds):len(r.cmds)], cmds...)
+
+		if r.providedBy == nil {
+			r.providedBy = map[string]int{}
+		}
+		for _, cmd := range cmds {
+			r.providedBy[cmd.Name] = i + 1
+		}
+	}
+	return nil
+}
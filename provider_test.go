@@ -0,0 +1,61 @@
+package acmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+type testProvider struct {
+	cached []string
+	remote []string
+	err    error
+}
+
+func (p *testProvider) List(ctx context.Context) ([]Command, error) {
+	names := p.remote
+	if IsOffline(ctx) {
+		names = p.cached
+	} else if p.err != nil {
+		return nil, p.err
+	}
+
+	cmds := make([]Command, len(names))
+	for i, name := range names {
+		cmds[i] = Command{Name: name, ExecFunc: nopFunc}
+	}
+	return cmds, nil
+}
+
+func TestProviders(t *testing.T) {
+	errNetwork := errors.New("network is down")
+	p := &testProvider{cached: []string{"deploy"}, remote: []string{"deploy", "rollback"}}
+
+	run := func(args ...string) error {
+		r := RunnerOf([]Command{{Name: "status", ExecFunc: nopFunc}}, Config{
+			Args:      append([]string{"./myapp"}, args...),
+			Output:    io.Discard,
+			Providers: []CommandProvider{p},
+		})
+		return r.Run()
+	}
+
+	failIfErr(t, run("status"))
+	failIfErr(t, run("rollback"))
+	failIfOk(t, run("--offline", "rollback"))
+	failIfErr(t, run("--offline", "deploy"))
+
+	p.err = errNetwork
+	if err := run("status"); !errors.Is(err, errNetwork) {
+		t.Fatal(err)
+	}
+	failIfErr(t, run("--offline", "deploy"))
+
+	p.err = nil
+	p.remote = []string{"status"}
+	var errDuplicate ErrDuplicateCommand
+	if !errors.As(run("status"), &errDuplicate) {
+		t.Fatal("must be duplicate")
+	}
+}
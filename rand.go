@@ -0,0 +1,44 @@
+package acmd
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Rand returns a random generator of the command run with ctx.
+// It is seeded with --seed flag if set, so randomized behavior (sampling, jitter)
+// can be reproduced. If the command fails, the seed is printed to ErrOutput.
+// The same generator is returned for the whole run, it is not safe for concurrent use.
+// Returns a new time-seeded generator if ctx is not the one passed to the command by the Runner.
+func Rand(ctx context.Context) *rand.Rand {
+	inv := invocationFrom(ctx)
+	if inv == nil {
+		return rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if inv.rand == nil {
+		inv.seed = inv.runner.seed
+		if !inv.runner.hasSeed {
+			inv.seed = time.Now().UnixNano()
+		}
+		inv.rand = rand.New(rand.NewSource(inv.seed))
+	}
+	return inv.rand
+}
+
+// printSeed used by Rand (if any) to reproduce the failed run.
+func (inv *invocation) printSeed() {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if inv.rand == nil {
+		return
+	}
+	cfg := inv.runner.cfg
+	fmt.Fprintf(cfg.ErrOutput, "%s: random seed %d, run with --seed=%d to reproduce\n", cfg.AppName, inv.seed, inv.seed)
+}
@@ -0,0 +1,80 @@
+package acmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRand(t *testing.T) {
+	var got []int
+	cmds := []Command{{
+		Name: "sample",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			mustEqual(t, Rand(ctx), Rand(ctx))
+			got = append(got, Rand(ctx).Intn(1000000))
+			return nil
+		},
+	}}
+
+	for i := 0; i < 2; i++ {
+		r := RunnerOf(cmds, Config{
+			Args:   []string{"./someapp", "--seed", "42", "sample"},
+			Output: io.Discard,
+		})
+		failIfErr(t, r.Run())
+	}
+	mustEqual(t, got[0], got[1])
+
+	r := RunnerOf(cmds, Config{
+		Args:   []string{"./someapp", "--seed=abc", "sample"},
+		Output: io.Discard,
+	})
+	if err := r.Run(); err == nil || err.Error() != `invalid --seed value "abc"` {
+		t.Fatal(err)
+	}
+
+	if Rand(context.Background()) == nil {
+		t.Fatal("must not be nil")
+	}
+}
+
+func TestRand_seedOnFailure(t *testing.T) {
+	errBoom := errors.New("boom")
+	cmds := []Command{
+		{
+			Name: "sample",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				Rand(ctx).Int()
+				return errBoom
+			},
+		},
+		{
+			Name: "fail",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				return errBoom
+			},
+		},
+	}
+
+	errBuf := &bytes.Buffer{}
+	r := RunnerOf(cmds, Config{
+		AppName:   "myapp",
+		Args:      []string{"./myapp", "--seed=7", "sample"},
+		Output:    io.Discard,
+		ErrOutput: errBuf,
+	})
+	mustEqual(t, r.Run(), errBoom)
+	mustEqual(t, errBuf.String(), "myapp: random seed 7, run with --seed=7 to reproduce\n")
+
+	errBuf.Reset()
+	r = RunnerOf(cmds, Config{
+		Args:      []string{"./myapp", "fail"},
+		Output:    io.Discard,
+		ErrOutput: errBuf,
+	})
+	mustEqual(t, r.Run(), errBoom)
+	mustEqual(t, errBuf.String(), "")
+}
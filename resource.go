@@ -0,0 +1,33 @@
+package acmd
+
+import (
+	"context"
+	"sort"
+)
+
+// ResourceCommands returns a command for the resource with a subcommand per verb,
+// ex: `myapp pod get` and `myapp pod delete`, like kubectl-style CLIs do.
+// Subcommands are described as "<verb> <resource>" and share the flags (can be nil).
+func ResourceCommands(resource string, verbs map[string]func(ctx context.Context, args []string) error, flags FlagsGetter) Command {
+	names := make([]string, 0, len(verbs))
+	for verb := range verbs {
+		names = append(names, verb)
+	}
+	sort.Strings(names)
+
+	subcmds := make([]Command, len(names))
+	for i, verb := range names {
+		subcmds[i] = Command{
+			Name:        verb,
+			Description: verb + " " + resource,
+			ExecFunc:    verbs[verb],
+			FlagSet:     flags,
+		}
+	}
+
+	return Command{
+		Name:        resource,
+		Description: "manages " + resource,
+		Subcommands: subcmds,
+	}
+}
@@ -0,0 +1,100 @@
+package acmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// NotifyReady tells systemd that the command (ex: `serve`) finished its startup.
+// Does nothing if Config.SystemdNotify is not set, NOTIFY_SOCKET env is not set
+// or ctx is not the one passed to the command by the Runner.
+func NotifyReady(ctx context.Context) error {
+	return notify(ctx, "READY=1")
+}
+
+// NotifyStatus sends a free-form status of the command to systemd, shown by `systemctl status`.
+// Does nothing in the same cases as NotifyReady.
+func NotifyStatus(ctx context.Context, status string) error {
+	return notify(ctx, "STATUS="+status)
+}
+
+func notify(ctx context.Context, state string) error {
+	inv := invocationFrom(ctx)
+	if inv == nil || !inv.runner.cfg.SystemdNotify {
+		return nil
+	}
+	return sdNotify(state)
+}
+
+// startSystemdNotify sends watchdog pings while the command runs if Config.SystemdNotify is set.
+// Returned func stops the pings and sends STOPPING=1.
+func (r *Runner) startSystemdNotify() func() {
+	if !r.cfg.SystemdNotify || os.Getenv("NOTIFY_SOCKET") == "" {
+		return func() {}
+	}
+
+	interval := watchdogInterval()
+	if interval == 0 {
+		return func() { sdNotify("STOPPING=1") }
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				sdNotify("WATCHDOG=1")
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		sdNotify("STOPPING=1")
+	}
+}
+
+// watchdogInterval is a half of the systemd watchdog timeout for this process, 0 if disabled.
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// sdNotify sends the state to the socket from NOTIFY_SOCKET env, see sd_notify(3).
+// Does nothing if the env is not set.
+func sdNotify(state string) error {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return nil
+	}
+	if name[0] == '@' {
+		name = "\x00" + name[1:] // abstract namespace socket.
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
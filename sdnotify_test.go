@@ -0,0 +1,70 @@
+package acmd
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSystemdNotify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported")
+	}
+
+	dir, err := os.MkdirTemp("", "sd")
+	failIfErr(t, err)
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: name, Net: "unixgram"})
+	failIfErr(t, err)
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", name)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", "")
+
+	cmds := []Command{{
+		Name: "serve",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			failIfErr(t, NotifyStatus(ctx, "starting"))
+			failIfErr(t, NotifyReady(ctx))
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		},
+	}}
+	r := RunnerOf(cmds, Config{
+		Args:          []string{"./myapp", "serve"},
+		Output:        io.Discard,
+		SystemdNotify: true,
+	})
+	failIfErr(t, r.Run())
+
+	var got []string
+	buf := make([]byte, 64)
+	for {
+		failIfErr(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		n, err := conn.Read(buf)
+		failIfErr(t, err)
+		got = append(got, string(buf[:n]))
+		if got[len(got)-1] == "STOPPING=1" {
+			break
+		}
+	}
+
+	if len(got) < 4 {
+		t.Fatalf("want at least one watchdog ping, got %q", got)
+	}
+	mustEqual(t, got[:2], []string{"STATUS=starting", "READY=1"})
+	for _, state := range got[2 : len(got)-1] {
+		mustEqual(t, state, "WATCHDOG=1")
+	}
+
+	// no-op when not enabled.
+	failIfErr(t, NotifyReady(context.Background()))
+}
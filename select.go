@@ -0,0 +1,203 @@
+package acmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Select asks the user to choose one of the options, returns index of the chosen option.
+// Options are numbered, the user can enter a number or a text to filter the options.
+// Input and output of the Runner are used if ctx is from the Runner, os.Stdin and os.Stdout otherwise.
+// Returns ErrNoInput right away if the app is run with --no-input.
+func Select(ctx context.Context, label string, options []string) (int, error) {
+	if NoInput(ctx) {
+		return 0, errNoInputFor(label)
+	}
+	in, out := promptIO(ctx)
+	idx, err := choose(in, out, label, optionRows(options), false)
+	if err != nil {
+		return 0, err
+	}
+	return idx[0], nil
+}
+
+// MultiSelect asks the user to choose any of the options, returns indexes of the chosen options.
+// The user can enter numbers separated by spaces or commas, `all` or a text to filter the options.
+// Input and output of the Runner are used if ctx is from the Runner, os.Stdin and os.Stdout otherwise.
+// Returns ErrNoInput right away if the app is run with --no-input.
+func MultiSelect(ctx context.Context, label string, options []string) ([]int, error) {
+	if NoInput(ctx) {
+		return nil, errNoInputFor(label)
+	}
+	in, out := promptIO(ctx)
+	return choose(in, out, label, optionRows(options), true)
+}
+
+func optionRows(options []string) [][]string {
+	rows := make([][]string, len(options))
+	for i, opt := range options {
+		rows[i] = []string{opt}
+	}
+	return rows
+}
+
+// errNoInputFor the prompt with label when prompts are disabled by --no-input.
+func errNoInputFor(label string) error {
+	return fmt.Errorf("%w: %q requires an answer but --no-input is set", ErrNoInput, label)
+}
+
+// promptIO returns input and output for the interactive helpers.
+func promptIO(ctx context.Context) (io.Reader, io.Writer) {
+	if inv := invocationFrom(ctx); inv != nil {
+		return inv.runner.cfg.Input, inv.runner.cfg.Output
+	}
+	return os.Stdin, os.Stdout
+}
+
+// selectCommand lets the user pick a command from the list of visible commands.
+func (r *Runner) selectCommand() ([]string, error) {
+	var paths []string
+	var rows [][]string
+	walkVisible(&r.cfg, r.cmds, nil, func(path []string, cmd *Command) bool {
+		if len(cmd.Subcommands) == 0 {
+			paths = append(paths, strings.Join(path, " "))
+			rows = append(rows, []string{strings.Join(path, " "), cmd.Description})
+		}
+		return true
+	})
+
+	idx, err := choose(r.cfg.Input, r.cfg.Output, "Select a command", rows, false)
+	if err != nil {
+		if errors.Is(err, ErrNoInput) {
+			return nil, ErrNoArgs
+		}
+		return nil, err
+	}
+	return strings.Fields(paths[idx[0]]), nil
+}
+
+// choose shows numbered rows and reads the user choice.
+// Number selects a row, text filters the rows, empty line resets the filter.
+// In multi mode many numbers (or `all`) can be entered.
+func choose(in io.Reader, w io.Writer, label string, rows [][]string, multi bool) ([]int, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("nothing to select from")
+	}
+
+	all := make([]int, len(rows))
+	for i := range rows {
+		all[i] = i
+	}
+	shown := all
+
+	hint := "number or text to filter"
+	if multi {
+		hint = "numbers, all or text to filter"
+	}
+
+	for {
+		t := &Table{Indent: "  ", Padding: 4}
+		for i, idx := range shown {
+			row := append([]string{fmt.Sprintf("%d) %s", i+1, rows[idx][0])}, rows[idx][1:]...)
+			t.AddRow(row...)
+		}
+		t.Render(w)
+		fmt.Fprintf(w, "%s (%s): ", label, hint)
+
+		line, err := readLine(in)
+		if err != nil {
+			fmt.Fprintln(w)
+			return nil, err
+		}
+
+		if multi && line == "all" {
+			return shown, nil
+		}
+		if nums, ok := parseNumbers(line, multi); ok {
+			var res []int
+			for _, n := range nums {
+				if n < 1 || n > len(shown) {
+					fmt.Fprintf(w, "%d is out of range\n\n", n)
+					res = nil
+					break
+				}
+				res = append(res, shown[n-1])
+			}
+			if res != nil {
+				return res, nil
+			}
+			continue
+		}
+
+		shown = filterRows(rows, all, line)
+		switch {
+		case len(shown) == 0:
+			fmt.Fprintf(w, "nothing matches %q\n\n", line)
+			shown = all
+		case len(shown) == 1 && !multi:
+			return shown, nil
+		default:
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+func filterRows(rows [][]string, all []int, text string) []int {
+	text = strings.ToLower(text)
+	var res []int
+	for _, idx := range all {
+		if strings.Contains(strings.ToLower(strings.Join(rows[idx], " ")), text) {
+			res = append(res, idx)
+		}
+	}
+	return res
+}
+
+// parseNumbers from the line, many numbers are allowed only in multi mode.
+func parseNumbers(line string, multi bool) ([]int, bool) {
+	fields := strings.FieldsFunc(line, func(c rune) bool { return c == ',' || c == ' ' })
+	if len(fields) == 0 || (!multi && len(fields) > 1) {
+		return nil, false
+	}
+
+	nums := make([]int, len(fields))
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, false
+		}
+		nums[i] = n
+	}
+	return nums, true
+}
+
+// readLine from r without buffering, so nothing is lost between the prompts.
+// Returns ErrNoInput if there is nothing to read.
+func readLine(r io.Reader) (string, error) {
+	var sb strings.Builder
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n == 1 {
+			if buf[0] == '\n' {
+				return strings.TrimSpace(sb.String()), nil
+			}
+			sb.WriteByte(buf[0])
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			if sb.Len() == 0 {
+				return "", ErrNoInput
+			}
+			return strings.TrimSpace(sb.String()), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package acmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	options := []string{"dev", "staging", "prod"}
+
+	var got []int
+	cmds := []Command{
+		{
+			Name: "deploy",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				idx, err := Select(ctx, "Environment", options)
+				if err != nil {
+					return err
+				}
+				got = append(got, idx)
+
+				idxs, err := MultiSelect(ctx, "Regions", []string{"eu", "us", "asia"})
+				if err != nil {
+					return err
+				}
+				got = append(got, idxs...)
+				return nil
+			},
+		},
+	}
+
+	testCases := []struct {
+		input      string
+		want       []int
+		wantErrStr string
+	}{
+		{"3\n1,3\n", []int{2, 0, 2}, ""},
+		{"stag\nall\n", []int{1, 0, 1, 2}, ""},
+		{"9\nd\n2\nu\n1 2\n", []int{2, 0, 1}, ""},
+		{"1\n", nil, "no input provided"},
+	}
+
+	for _, tc := range testCases {
+		got = nil
+		buf := &bytes.Buffer{}
+		r := RunnerOf(cmds, Config{
+			Args:   []string{"./someapp", "deploy"},
+			Output: buf,
+			Input:  strings.NewReader(tc.input),
+		})
+
+		err := r.Run()
+		if tc.wantErrStr != "" {
+			failIfOk(t, err)
+			mustEqual(t, err.Error(), tc.wantErrStr)
+			continue
+		}
+		failIfErr(t, err)
+		mustEqual(t, got, tc.want)
+	}
+}
+
+func TestSelect_noInput(t *testing.T) {
+	var noInput bool
+	cmds := []Command{{
+		Name: "deploy",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			noInput = NoInput(ctx)
+			_, err := Select(ctx, "Environment", []string{"dev", "prod"})
+			return err
+		},
+	}}
+
+	buf := &bytes.Buffer{}
+	r := RunnerOf(cmds, Config{
+		Args:   []string{"./someapp", "--no-input", "deploy"},
+		Output: buf,
+		Input:  strings.NewReader("1\n"),
+	})
+
+	err := r.Run()
+	if !errors.Is(err, ErrNoInput) {
+		t.Fatal(err)
+	}
+	mustEqual(t, err.Error(), `no input provided: "Environment" requires an answer but --no-input is set`)
+	mustEqual(t, noInput, true)
+	mustEqual(t, buf.String(), "")
+	mustEqual(t, NoInput(context.Background()), false)
+}
+
+func TestSelectOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	idx, err := choose(strings.NewReader("p\n"), buf, "Environment", optionRows([]string{"dev", "prod"}), false)
+	failIfErr(t, err)
+	mustEqual(t, idx, []int{1})
+
+	want := "" +
+		"  1) dev\n" +
+		"  2) prod\n" +
+		"Environment (number or text to filter): "
+	mustEqual(t, buf.String(), want)
+
+	_, err = choose(strings.NewReader(""), io.Discard, "Empty", nil, false)
+	failIfOk(t, err)
+}
@@ -0,0 +1,80 @@
+package acmd
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep pauses for d or until ctx is done, whichever happens first.
+// Returns ctx.Err() if ctx is done before d passes.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Backoff is an exponential backoff honoring context cancellation.
+// The zero value is ready to use, ex:
+//
+//	var b acmd.Backoff
+//	for {
+//		if err := try(); err == nil {
+//			break
+//		}
+//		if err := b.Next(ctx); err != nil {
+//			return err
+//		}
+//	}
+type Backoff struct {
+	// Min is the first delay. Default is 100ms.
+	Min time.Duration
+
+	// Max is the upper bound of the delay. Default is 10s.
+	Max time.Duration
+
+	attempt int
+}
+
+// Next waits for the next delay, which is doubled on each call up to Max.
+// Returns ctx.Err() if ctx is done before the delay passes.
+func (b *Backoff) Next(ctx context.Context) error {
+	d := b.Delay()
+	b.attempt++
+	return Sleep(ctx, d)
+}
+
+// Delay returns the delay to be used by the next call of Next.
+func (b *Backoff) Delay() time.Duration {
+	min, max := b.Min, b.Max
+	if min <= 0 {
+		min = 100 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 10 * time.Second
+	}
+
+	d := min
+	for i := 0; i < b.attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// Attempt returns how many times Next was called since the last Reset.
+func (b *Backoff) Attempt() int {
+	return b.attempt
+}
+
+// Reset the backoff to the first delay.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
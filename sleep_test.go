@@ -0,0 +1,50 @@
+package acmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSleep(t *testing.T) {
+	failIfErr(t, Sleep(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if err := Sleep(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("sleep must return on cancel")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Min: time.Millisecond, Max: 5 * time.Millisecond}
+
+	var delays []time.Duration
+	for i := 0; i < 5; i++ {
+		delays = append(delays, b.Delay())
+		failIfErr(t, b.Next(context.Background()))
+	}
+	mustEqual(t, delays, []time.Duration{
+		time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond,
+		5 * time.Millisecond, 5 * time.Millisecond,
+	})
+	mustEqual(t, b.Attempt(), 5)
+
+	b.Reset()
+	mustEqual(t, b.Delay(), time.Millisecond)
+
+	var zero Backoff
+	mustEqual(t, zero.Delay(), 100*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := zero.Next(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+}
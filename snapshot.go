@@ -0,0 +1,138 @@
+package acmd
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SnapshotOptions filter the command tree written by Runner.Snapshot.
+type SnapshotOptions struct {
+	// Hidden commands are included, marked with `hidden: true`. Default is false.
+	Hidden bool
+
+	// NoAliases leaves out aliases of the commands. Default is false.
+	NoAliases bool
+
+	// MaxDepth of the commands, ex: 1 for the top-level commands only. Zero means no limit.
+	MaxDepth int
+
+	// FrontMatter returns extra lines written after the path of the command, ex: `owner: team-a`.
+	// Lines are indented like the other values of the command.
+	FrontMatter func(path []string, cmd *Command) []string
+}
+
+// Snapshot returns the whole command tree with flags in a stable plain-text form.
+// It's intended to be checked into the repository, so changes to the CLI are visible in diffs.
+// Values which can differ between runs are left out: commands of Config.Providers and flag defaults.
+// Same output is printed by `help --all --plain`, `help --all` shows these values too.
+// Filters are set in opts, `help --all` supports --hidden, --no-aliases and --depth=N flags for them.
+func (r *Runner) Snapshot(opts SnapshotOptions) string {
+	return r.commandTree(true, opts)
+}
+
+// commandTree for Snapshot if plain is true or for `help --all` with all the values.
+func (r *Runner) commandTree(plain bool, opts SnapshotOptions) string {
+	cmds := r.cmds
+	if plain && len(r.providedBy) != 0 {
+		cmds = make([]Command, 0, len(r.cmds))
+		for _, cmd := range r.cmds {
+			if _, ok := r.providedBy[cmd.Name]; !ok {
+				cmds = append(cmds, cmd)
+			}
+		}
+	}
+
+	w := &treeWriter{cfg: &r.cfg, plain: plain, opts: opts}
+	w.writeCommands(cmds, nil)
+	return w.sb.String()
+}
+
+// snapshotOptionsFrom the args of `help --all`.
+func snapshotOptionsFrom(args []string) (SnapshotOptions, error) {
+	opts := SnapshotOptions{
+		Hidden:    hasArg(args, "--hidden"),
+		NoAliases: hasArg(args, "--no-aliases"),
+	}
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--depth=") {
+			continue
+		}
+		depth := strings.TrimPrefix(arg, "--depth=")
+		n, err := strconv.Atoi(depth)
+		if err != nil || n < 1 {
+			return opts, fmt.Errorf("invalid --depth value %q", depth)
+		}
+		opts.MaxDepth = n
+	}
+	return opts, nil
+}
+
+// treeWriter writes the command tree for Runner.Snapshot.
+type treeWriter struct {
+	cfg   *Config
+	sb    strings.Builder
+	plain bool
+	opts  SnapshotOptions
+}
+
+func (w *treeWriter) writeCommands(cmds []Command, parent []string) {
+	if w.opts.MaxDepth > 0 && len(parent) >= w.opts.MaxDepth {
+		return
+	}
+
+	sb := &w.sb
+	for i := range cmds {
+		cmd := &cmds[i]
+		if cmd.IsHidden && !w.opts.Hidden {
+			continue
+		}
+		path := append(parent[:len(parent):len(parent)], cmd.Name)
+
+		fmt.Fprintf(sb, "%s\n", strings.Join(path, " "))
+		if w.opts.FrontMatter != nil {
+			for _, line := range w.opts.FrontMatter(path, cmd) {
+				fmt.Fprintf(sb, "    %s\n", line)
+			}
+		}
+		if cmd.Alias != "" && !w.opts.NoAliases {
+			fmt.Fprintf(sb, "    alias: %s\n", cmd.Alias)
+		}
+		if cmd.Description != "" {
+			fmt.Fprintf(sb, "    description: %s\n", cmd.Description)
+		}
+		if cmd.IsHidden {
+			fmt.Fprintf(sb, "    hidden: true\n")
+		}
+		if cmd.Experimental {
+			fmt.Fprintf(sb, "    experimental: true\n")
+		}
+		if len(cmd.Platforms) != 0 {
+			fmt.Fprintf(sb, "    platforms: %s\n", strings.Join(cmd.Platforms, ", "))
+		}
+		for i, choices := range cmd.ArgChoices {
+			if len(choices) != 0 {
+				fmt.Fprintf(sb, "    arg %d: %s\n", i+1, strings.Join(choices, "|"))
+			}
+		}
+		if cmd.FlagSet != nil {
+			cmd.FlagSet.Flags().VisitAll(func(f *flag.Flag) {
+				name, usage := flag.UnquoteUsage(f)
+				fmt.Fprintf(sb, "    flag: -%s", f.Name)
+				if name != "" {
+					fmt.Fprintf(sb, " %s", name)
+				}
+				if !w.plain && f.DefValue != "" {
+					fmt.Fprintf(sb, " (default %q)", f.DefValue)
+				}
+				fmt.Fprintf(sb, ": %s\n", usage)
+			})
+		}
+
+		if err := cmd.loadSubcommands(w.cfg, path); err != nil {
+			fmt.Fprintf(sb, "    error: %s\n", err)
+		}
+		w.writeCommands(cmd.Subcommands, path)
+	}
+}
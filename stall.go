@@ -0,0 +1,103 @@
+package acmd
+
+import (
+	"io"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// stallWatchdog tracks the last activity of the running command.
+type stallWatchdog struct {
+	last int64 // unix nanoseconds, accessed atomically.
+}
+
+func (wd *stallWatchdog) touch() {
+	atomic.StoreInt64(&wd.last, time.Now().UnixNano())
+}
+
+func (wd *stallWatchdog) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&wd.last)))
+}
+
+// activityWriter marks every write as an activity of the command.
+type activityWriter struct {
+	w  io.Writer
+	wd *stallWatchdog
+}
+
+func (w activityWriter) Write(p []byte) (int, error) {
+	w.wd.touch()
+	return w.w.Write(p)
+}
+
+// startStallWatchdog for the command if Config.StallTimeout is set.
+// Returned func stops the watchdog and restores Output and ErrOutput.
+func (r *Runner) startStallWatchdog(inv *invocation) func() {
+	timeout := r.cfg.StallTimeout
+	if timeout <= 0 {
+		return func() {}
+	}
+
+	wd := &stallWatchdog{}
+	wd.touch()
+	inv.stall = wd
+
+	r.outputMu.Lock()
+	oldOutput, oldErrOutput := r.cfg.Output, r.cfg.ErrOutput
+	r.cfg.Output = activityWriter{w: oldOutput, wd: wd}
+	r.cfg.ErrOutput = activityWriter{w: oldErrOutput, wd: wd}
+	r.outputMu.Unlock()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-timer.C:
+			}
+
+			if idle := wd.idle(); idle < timeout {
+				timer.Reset(timeout - idle)
+				continue
+			}
+
+			if r.cfg.StallAbort {
+				r.terminate(inv, "%s: command %q stalled for %v, aborting\n\n%s",
+					r.cfg.AppName, strings.Join(inv.path, " "), timeout, allStacks())
+				return
+			}
+			r.printErr("%s: still working...\n", r.cfg.AppName)
+			wd.touch()
+			timer.Reset(timeout)
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		r.outputMu.Lock()
+		r.cfg.Output, r.cfg.ErrOutput = oldOutput, oldErrOutput
+		r.outputMu.Unlock()
+	}
+}
+
+// allStacks returns stack traces of all goroutines.
+func allStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,109 @@
+package acmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStallTimeout(t *testing.T) {
+	errBuf := &bytes.Buffer{}
+	cmds := []Command{{
+		Name: "sync",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		},
+	}}
+	r := RunnerOf(cmds, Config{
+		AppName:      "myapp",
+		Args:         []string{"./myapp", "sync"},
+		Output:       io.Discard,
+		ErrOutput:    errBuf,
+		StallTimeout: 30 * time.Millisecond,
+	})
+	failIfErr(t, r.Run())
+
+	if !strings.HasPrefix(errBuf.String(), "myapp: still working...\n") {
+		t.Fatal(errBuf.String())
+	}
+	mustEqual(t, r.cfg.ErrOutput, io.Writer(errBuf))
+}
+
+func TestStallTimeout_activity(t *testing.T) {
+	errBuf := &bytes.Buffer{}
+	cmds := []Command{{
+		Name: "sync",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			for i := 0; i < 10; i++ {
+				Progress(ctx, "working")
+				time.Sleep(10 * time.Millisecond)
+			}
+			return nil
+		},
+	}}
+	r := RunnerOf(cmds, Config{
+		Args:         []string{"./myapp", "sync"},
+		Output:       io.Discard,
+		ErrOutput:    errBuf,
+		StallTimeout: time.Second,
+	})
+	failIfErr(t, r.Run())
+	mustEqual(t, errBuf.String(), "")
+}
+
+func TestStallAbort(t *testing.T) {
+	exitCode := make(chan int, 1)
+	doExitOld := func(code int) {
+		exitCode <- code
+	}
+	defer func() { doExit = doExitOld }()
+	doExitOld, doExit = doExit, doExitOld
+
+	pidFile := filepath.Join(t.TempDir(), "sync.pid")
+	var tempDir string
+
+	errBuf := &bytes.Buffer{}
+	cmds := []Command{{
+		Name:    "sync",
+		PIDFile: pidFile,
+		ExecFunc: func(ctx context.Context, args []string) error {
+			var err error
+			tempDir, err = TempDir(ctx)
+			failIfErr(t, err)
+
+			select {
+			case <-exitCode:
+				exitCode <- 1
+			case <-time.After(time.Second):
+			}
+			return nil
+		},
+	}}
+	r := RunnerOf(cmds, Config{
+		AppName:      "myapp",
+		Args:         []string{"./myapp", "sync"},
+		Output:       io.Discard,
+		ErrOutput:    errBuf,
+		StallTimeout: 10 * time.Millisecond,
+		StallAbort:   true,
+	})
+	failIfErr(t, r.Run())
+
+	mustEqual(t, <-exitCode, 1)
+	if _, err := os.Stat(pidFile); !os.IsNotExist(err) {
+		t.Fatal("pid file must be removed")
+	}
+	if _, err := os.Stat(tempDir); !os.IsNotExist(err) {
+		t.Fatal("temp dir must be removed")
+	}
+	out := errBuf.String()
+	if !strings.HasPrefix(out, "myapp: command \"sync\" stalled for 10ms, aborting\n\ngoroutine ") {
+		t.Fatal(out)
+	}
+}
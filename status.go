@@ -0,0 +1,55 @@
+package acmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+type status struct {
+	symbol string
+	plain  string
+	color  string
+}
+
+var (
+	statusSuccess = status{symbol: "✓", plain: "[ok]", color: "\x1b[32m"}
+	statusFailure = status{symbol: "✗", plain: "[fail]", color: "\x1b[31m"}
+	statusInfo    = status{symbol: "ℹ", plain: "[info]", color: "\x1b[34m"}
+)
+
+// Success prints a status line about a successful step, ex: `✓ image pushed`.
+// Output of the Runner is used if ctx is from the Runner, os.Stdout otherwise.
+func Success(ctx context.Context, msg string) {
+	printStatus(ctx, Stdout(ctx), statusSuccess, msg)
+}
+
+// Failure prints a status line about a failed step, ex: `✗ tests failed`.
+// ErrOutput of the Runner is used if ctx is from the Runner, os.Stderr otherwise.
+func Failure(ctx context.Context, msg string) {
+	printStatus(ctx, Stderr(ctx), statusFailure, msg)
+}
+
+// Info prints an informational status line, ex: `ℹ using cached build`.
+// Output of the Runner is used if ctx is from the Runner, os.Stdout otherwise.
+func Info(ctx context.Context, msg string) {
+	printStatus(ctx, Stdout(ctx), statusInfo, msg)
+}
+
+// printStatus with a colored symbol on a terminal or a plain text prefix
+// if Config.Plain is set. Colors are disabled by NO_COLOR env.
+func printStatus(ctx context.Context, w Stream, st status, msg string) {
+	var plain bool
+	if inv := invocationFrom(ctx); inv != nil {
+		plain = inv.runner.cfg.Plain
+	}
+
+	switch {
+	case plain:
+		fmt.Fprintf(w, "%s %s\n", st.plain, msg)
+	case w.IsTTY() && os.Getenv("NO_COLOR") == "":
+		fmt.Fprintf(w, "%s%s\x1b[0m %s\n", st.color, st.symbol, msg)
+	default:
+		fmt.Fprintf(w, "%s %s\n", st.symbol, msg)
+	}
+}
@@ -0,0 +1,52 @@
+package acmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	cmds := []Command{{
+		Name: "deploy",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			Info(ctx, "using cached build")
+			Success(ctx, "image pushed")
+			Failure(ctx, "tests failed")
+			return nil
+		},
+	}}
+
+	for _, plain := range []bool{false, true} {
+		buf, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+		r := RunnerOf(cmds, Config{
+			Args:      []string{"./someapp", "deploy"},
+			Output:    buf,
+			ErrOutput: errBuf,
+			Plain:     plain,
+		})
+		failIfErr(t, r.Run())
+
+		if plain {
+			mustEqual(t, buf.String(), "[info] using cached build\n[ok] image pushed\n")
+			mustEqual(t, errBuf.String(), "[fail] tests failed\n")
+		} else {
+			mustEqual(t, buf.String(), "ℹ using cached build\n✓ image pushed\n")
+			mustEqual(t, errBuf.String(), "✗ tests failed\n")
+		}
+	}
+}
+
+func TestPrintStatusColor(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := Stream{Writer: buf, tty: true}
+
+	t.Setenv("NO_COLOR", "")
+	printStatus(context.Background(), w, statusSuccess, "done")
+	mustEqual(t, buf.String(), "\x1b[32m✓\x1b[0m done\n")
+
+	buf.Reset()
+	t.Setenv("NO_COLOR", "1")
+	printStatus(context.Background(), w, statusSuccess, "done")
+	mustEqual(t, buf.String(), "✓ done\n")
+}
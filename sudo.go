@@ -0,0 +1,49 @@
+package acmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ReexecWithSudo runs the app again under sudo with the same args (see RawArgs),
+// environment variables from Config.SudoEnv are preserved.
+// Returns ErrCode with the exit code if the re-executed app fails.
+func ReexecWithSudo(ctx context.Context) error {
+	inv := invocationFrom(ctx)
+	if inv == nil {
+		return errors.New("reexec with sudo: context is not from the Runner")
+	}
+	return inv.runner.reexecWithSudo(ctx)
+}
+
+func (r *Runner) reexecWithSudo(ctx context.Context) error {
+	if geteuid() == 0 {
+		return errors.New("reexec with sudo: already running as root")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	var args []string
+	if len(r.cfg.SudoEnv) != 0 {
+		args = append(args, "--preserve-env="+strings.Join(r.cfg.SudoEnv, ","))
+	}
+	args = append(args, "--", exe)
+	args = append(args, r.rawArgs[1:]...)
+
+	cmd := exec.CommandContext(ctx, sudoBin, args...)
+	cmd.Stdin = r.cfg.Input
+	cmd.Stdout = r.cfg.Output
+	cmd.Stderr = r.cfg.ErrOutput
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return ErrCode(exitErr.ExitCode())
+	}
+	return err
+}
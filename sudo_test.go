@@ -0,0 +1,48 @@
+package acmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestReexecWithSudo(t *testing.T) {
+	echo, err := exec.LookPath("echo")
+	if err != nil {
+		t.Skip("echo is not installed")
+	}
+
+	defer func(f func() int, s string) { geteuid, sudoBin = f, s }(geteuid, sudoBin)
+	geteuid = func() int { return 1000 }
+	sudoBin = echo
+
+	exe, err := os.Executable()
+	failIfErr(t, err)
+
+	buf := &bytes.Buffer{}
+	cmds := []Command{{
+		Name: "install",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			return ReexecWithSudo(ctx)
+		},
+	}}
+	r := RunnerOf(cmds, Config{
+		Args:    []string{"./myapp", "--no-pager", "install", "-f"},
+		Output:  buf,
+		SudoEnv: []string{"HOME", "LANG"},
+	})
+	failIfErr(t, r.Run())
+	mustEqual(t, buf.String(), "--preserve-env=HOME,LANG -- "+exe+" --no-pager install -f\n")
+
+	err = ReexecWithSudo(context.Background())
+	failIfOk(t, err)
+
+	geteuid = func() int { return 0 }
+	r = RunnerOf(cmds, Config{Args: []string{"./myapp", "install"}, Output: buf})
+	if err := r.Run(); err == nil || errors.Is(err, ErrRequiresRoot) {
+		t.Fatal(err)
+	}
+}
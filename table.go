@@ -0,0 +1,178 @@
+package acmd
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Table prints rows aligned in columns, the same way as commands are printed in help.
+// Zero value is ready to use.
+type Table struct {
+	// Indent is printed before every line.
+	Indent string
+
+	// Padding between columns. Default is 2.
+	Padding int
+
+	// MaxWidth of a cell, longer values are truncated with "…". Zero means no limit.
+	MaxWidth int
+
+	// Width of a line, the last cell of the row is truncated with "…" to fit it
+	// (ex: to the terminal width). Ignored with Borders. Zero means no limit.
+	Width int
+
+	// Borders around the table and between the columns. Default false.
+	Borders bool
+
+	// Color the headers (bold) using ANSI escape codes. Default false.
+	Color bool
+
+	headers []string
+	rows    [][]string
+}
+
+// SetHeaders of the table.
+func (t *Table) SetHeaders(headers ...string) {
+	t.headers = headers
+}
+
+// AddRow to the table.
+// Without borders, the last cell of the row is not aligned, so a row with a single cell
+// is printed as is and doesn't affect the width of the columns.
+func (t *Table) AddRow(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// Render the table to w.
+func (t *Table) Render(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	rows := t.rows
+	if len(t.headers) != 0 {
+		rows = append([][]string{t.headers}, rows...)
+	}
+	for i := range rows {
+		rows[i] = t.truncate(rows[i])
+	}
+	widths := t.columnWidths(rows)
+
+	if t.Borders {
+		t.renderBorders(bw, rows, widths)
+	} else {
+		t.renderPlain(bw, rows, widths)
+	}
+	return bw.Flush()
+}
+
+func (t *Table) renderPlain(bw *bufio.Writer, rows [][]string, widths []int) {
+	padding := t.Padding
+	if padding == 0 {
+		padding = 2
+	}
+
+	for i, row := range rows {
+		bw.WriteString(t.Indent)
+		lineWidth := cellWidth(t.Indent)
+		for j, cell := range row {
+			if j == len(row)-1 {
+				cell = t.fitLine(cell, lineWidth)
+			}
+			isHeader := i == 0 && len(t.headers) != 0
+			t.writeCell(bw, cell, isHeader)
+
+			if j < len(row)-1 {
+				pad := widths[j] - cellWidth(cell) + padding
+				bw.WriteString(strings.Repeat(" ", pad))
+				lineWidth += cellWidth(cell) + pad
+			}
+		}
+		bw.WriteString("\n")
+	}
+}
+
+func (t *Table) renderBorders(bw *bufio.Writer, rows [][]string, widths []int) {
+	line := t.Indent + "+"
+	for _, w := range widths {
+		line += strings.Repeat("-", w+2) + "+"
+	}
+	line += "\n"
+
+	bw.WriteString(line)
+	for i, row := range rows {
+		bw.WriteString(t.Indent + "|")
+		for j, w := range widths {
+			cell := ""
+			if j < len(row) {
+				cell = row[j]
+			}
+			bw.WriteString(" ")
+			t.writeCell(bw, cell, i == 0 && len(t.headers) != 0)
+			bw.WriteString(strings.Repeat(" ", w-cellWidth(cell)+1) + "|")
+		}
+		bw.WriteString("\n")
+
+		if i == 0 && len(t.headers) != 0 {
+			bw.WriteString(line)
+		}
+	}
+	bw.WriteString(line)
+}
+
+func (t *Table) writeCell(bw *bufio.Writer, cell string, isHeader bool) {
+	if isHeader && t.Color {
+		bw.WriteString("\x1b[1m" + cell + "\x1b[0m")
+		return
+	}
+	bw.WriteString(cell)
+}
+
+// columnWidths counts widths of all columns, the last cell of the row is ignored without borders.
+func (t *Table) columnWidths(rows [][]string) []int {
+	var widths []int
+	for _, row := range rows {
+		for j, cell := range row {
+			if !t.Borders && j == len(row)-1 {
+				break
+			}
+			for len(widths) <= j {
+				widths = append(widths, 0)
+			}
+			if w := cellWidth(cell); w > widths[j] {
+				widths[j] = w
+			}
+		}
+	}
+	return widths
+}
+
+func (t *Table) truncate(row []string) []string {
+	if t.MaxWidth <= 0 {
+		return row
+	}
+
+	res := make([]string, len(row))
+	for i, cell := range row {
+		res[i] = cell
+		if cellWidth(cell) > t.MaxWidth {
+			res[i] = string([]rune(cell)[:t.MaxWidth-1]) + "…"
+		}
+	}
+	return res
+}
+
+// fitLine truncates the last cell to fit Width when lineWidth is already taken.
+// Cell is kept as is if there is not enough space even for a few chars.
+func (t *Table) fitLine(cell string, lineWidth int) string {
+	const minCellWidth = 10
+	avail := t.Width - lineWidth
+	if t.Width <= 0 || avail < minCellWidth || cellWidth(cell) <= avail {
+		return cell
+	}
+	return string([]rune(cell)[:avail-1]) + "…"
+}
+
+func cellWidth(s string) int {
+	return utf8.RuneCountInString(s)
+}
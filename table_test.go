@@ -0,0 +1,69 @@
+package acmd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	testCases := []struct {
+		table Table
+		want  string
+	}{
+		{
+			table: Table{},
+			want: "" +
+				"NAME    DESCRIPTION\n" +
+				"foo     does foo\n" +
+				"barbaz  does bar and baz\n" +
+				"a single cell row is not aligned\n",
+		},
+		{
+			table: Table{Indent: "  ", Padding: 4, MaxWidth: 6},
+			want: "" +
+				"  NAME      DESCR…\n" +
+				"  foo       does …\n" +
+				"  barbaz    does …\n" +
+				"  a sin…\n",
+		},
+		{
+			table: Table{Width: 20},
+			want: "" +
+				"NAME    DESCRIPTION\n" +
+				"foo     does foo\n" +
+				"barbaz  does bar an…\n" +
+				"a single cell row i…\n",
+		},
+		{
+			table: Table{Borders: true},
+			want: "" +
+				"+----------------------------------+------------------+\n" +
+				"| NAME                             | DESCRIPTION      |\n" +
+				"+----------------------------------+------------------+\n" +
+				"| foo                              | does foo         |\n" +
+				"| barbaz                           | does bar and baz |\n" +
+				"| a single cell row is not aligned |                  |\n" +
+				"+----------------------------------+------------------+\n",
+		},
+		{
+			table: Table{Color: true},
+			want: "" +
+				"\x1b[1mNAME\x1b[0m    \x1b[1mDESCRIPTION\x1b[0m\n" +
+				"foo     does foo\n" +
+				"barbaz  does bar and baz\n" +
+				"a single cell row is not aligned\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		table := tc.table
+		table.SetHeaders("NAME", "DESCRIPTION")
+		table.AddRow("foo", "does foo")
+		table.AddRow("barbaz", "does bar and baz")
+		table.AddRow("a single cell row is not aligned")
+
+		buf := &bytes.Buffer{}
+		failIfErr(t, table.Render(buf))
+		mustEqual(t, buf.String(), tc.want)
+	}
+}
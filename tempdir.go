@@ -0,0 +1,44 @@
+package acmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// TempDir returns a temporary directory for the command run with ctx.
+// The directory is created on the first call and removed with everything inside
+// when the command finishes (after the undo steps, if any).
+// If ctx is not the one passed to the command by the Runner, a new directory
+// is created on each call and the caller must remove it.
+func TempDir(ctx context.Context) (string, error) {
+	inv := invocationFrom(ctx)
+	if inv == nil {
+		return os.MkdirTemp("", "acmd-")
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if inv.tempDir == "" {
+		// AppName can be a path (ex: /usr/bin/myapp) or contain characters not allowed in a file name.
+		prefix := escapeFileName(filepath.Base(inv.runner.cfg.AppName))
+		dir, err := os.MkdirTemp("", prefix+"-")
+		if err != nil {
+			return "", err
+		}
+		inv.tempDir = dir
+	}
+	return inv.tempDir, nil
+}
+
+// removeTempDir created by TempDir, if any.
+func (inv *invocation) removeTempDir() {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if inv.tempDir != "" {
+		os.RemoveAll(inv.tempDir)
+		inv.tempDir = ""
+	}
+}
@@ -0,0 +1,66 @@
+package acmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTempDir(t *testing.T) {
+	var dir string
+	cmds := []Command{{
+		Name: "build",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			var err error
+			dir, err = TempDir(ctx)
+			failIfErr(t, err)
+
+			again, err := TempDir(ctx)
+			failIfErr(t, err)
+			mustEqual(t, again, dir)
+
+			RegisterUndo(ctx, func(ctx context.Context) error {
+				_, err := os.Stat(filepath.Join(dir, "out"))
+				return err
+			})
+			failIfErr(t, os.WriteFile(filepath.Join(dir, "out"), []byte("data"), 0o600))
+			return errors.New("build failed")
+		},
+	}}
+
+	r := RunnerOf(cmds, Config{
+		AppName: "myapp",
+		Args:    []string{"./myapp", "build"},
+		Output:  io.Discard,
+	})
+	err := r.Run()
+	mustEqual(t, err.Error(), "build failed")
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("temp dir %s must be removed, got %v", dir, err)
+	}
+}
+
+func TestTempDir_appNamePath(t *testing.T) {
+	for _, app := range []string{"/usr/bin/myapp", `C:\bin\my:app.exe`, "/"} {
+		var dir string
+		cmds := []Command{{
+			Name: "build",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				var err error
+				dir, err = TempDir(ctx)
+				return err
+			},
+		}}
+		r := RunnerOf(cmds, Config{
+			AppName: app,
+			Args:    []string{"./myapp", "build"},
+			Output:  io.Discard,
+		})
+		failIfErr(t, r.Run())
+		mustEqual(t, filepath.Dir(dir), filepath.Clean(os.TempDir()))
+	}
+}
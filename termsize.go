@@ -0,0 +1,19 @@
+package acmd
+
+import (
+	"io"
+	"os"
+	"strconv"
+)
+
+// termWidth returns width of the terminal w in columns or 0 if w is not a terminal.
+// $COLUMNS environment variable takes precedence over the size reported by the terminal.
+func termWidth(w io.Writer) int {
+	if !IsTerminal(w) {
+		return 0
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return termSize(w.(*os.File))
+}
@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package acmd
+
+import "os"
+
+// termSize is not supported on this platform, only $COLUMNS is used.
+func termSize(f *os.File) int {
+	return 0
+}
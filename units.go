@@ -0,0 +1,187 @@
+package acmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1e3,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1e6,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1e9,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tb":  1e12,
+	"tib": 1 << 40,
+	"p":   1 << 50,
+	"pb":  1e15,
+	"pib": 1 << 50,
+}
+
+// ParseSize parses size in bytes like "512", "10KB", "1.5GiB" or "2T".
+// Units are case-insensitive, KB/MB/... are decimal, KiB/MiB/... and K/M/... are binary.
+func ParseSize(s string) (int64, error) {
+	num, unit := splitNumber(strings.TrimSpace(s))
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size %q: must be a non-negative number with an optional unit", s)
+	}
+
+	mult, ok := sizeUnits[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, unit)
+	}
+
+	// float64(math.MaxInt64) is rounded up to 2^63, which doesn't fit into int64.
+	size := f * mult
+	if size >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return int64(size), nil
+}
+
+// ParseDuration is like time.ParseDuration but also supports days (d) and weeks (w).
+// Ex: "1w2d", "3d12h" or "90m". A day is always 24 hours.
+func ParseDuration(s string) (time.Duration, error) {
+	str := strings.TrimSpace(s)
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(str, "-"):
+		sign, str = -1, str[1:]
+	case strings.HasPrefix(str, "+"):
+		str = str[1:]
+	}
+	if str == "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var total time.Duration
+	for str != "" {
+		num, rest := splitNumber(str)
+		unitLen := strings.IndexAny(rest, "0123456789.")
+		if unitLen == -1 {
+			unitLen = len(rest)
+		}
+		unit := rest[:unitLen]
+		str = rest[unitLen:]
+
+		var d time.Duration
+		switch unit {
+		case "d", "w":
+			f, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			f *= float64(24 * time.Hour)
+			if unit == "w" {
+				f *= 7
+			}
+			// float64(math.MaxInt64) is rounded up to 2^63, see ParseSize.
+			if f >= math.MaxInt64 {
+				return 0, fmt.Errorf("invalid duration %q: too large", s)
+			}
+			d = time.Duration(f)
+		default:
+			var err error
+			d, err = time.ParseDuration(num + unit)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+		}
+		if total > math.MaxInt64-d {
+			return 0, fmt.Errorf("invalid duration %q: too large", s)
+		}
+		total += d
+	}
+	return sign * total, nil
+}
+
+// ParsePercent parses percent like "50%" or "12.5" into a fraction (0.5 and 0.125).
+// Negative and non-finite (NaN, Inf) values are not allowed.
+func ParsePercent(s string) (float64, error) {
+	str := strings.TrimSuffix(strings.TrimSpace(s), "%")
+	f, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || f < 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid percent %q: must be a non-negative number with an optional %%", s)
+	}
+	return f / 100, nil
+}
+
+// splitNumber splits s into a leading number and the rest.
+func splitNumber(s string) (num, rest string) {
+	i := 0
+	for i < len(s) && (('0' <= s[i] && s[i] <= '9') || s[i] == '.') {
+		i++
+	}
+	return s[:i], strings.TrimSpace(s[i:])
+}
+
+// SizeValue is a flag.Value for sizes, see ParseSize.
+// Ex: fs.Var((*acmd.SizeValue)(&limit), "limit", "max size").
+type SizeValue int64
+
+func (v *SizeValue) Set(s string) error {
+	size, err := ParseSize(s)
+	if err != nil {
+		return err
+	}
+	*v = SizeValue(size)
+	return nil
+}
+
+func (v *SizeValue) String() string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.FormatInt(int64(*v), 10)
+}
+
+// DurationValue is a flag.Value for durations with days and weeks, see ParseDuration.
+// Ex: fs.Var((*acmd.DurationValue)(&ttl), "ttl", "time to live").
+type DurationValue time.Duration
+
+func (v *DurationValue) Set(s string) error {
+	d, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = DurationValue(d)
+	return nil
+}
+
+func (v *DurationValue) String() string {
+	if v == nil {
+		return "0s"
+	}
+	return time.Duration(*v).String()
+}
+
+// PercentValue is a flag.Value for percents stored as a fraction, see ParsePercent.
+// Ex: fs.Var((*acmd.PercentValue)(&ratio), "ratio", "sampling ratio").
+type PercentValue float64
+
+func (v *PercentValue) Set(s string) error {
+	f, err := ParsePercent(s)
+	if err != nil {
+		return err
+	}
+	*v = PercentValue(f)
+	return nil
+}
+
+func (v *PercentValue) String() string {
+	if v == nil {
+		return "0%"
+	}
+	return strconv.FormatFloat(float64(*v)*100, 'f', -1, 64) + "%"
+}
@@ -0,0 +1,126 @@
+package acmd
+
+import (
+	"flag"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		s          string
+		want       int64
+		wantErrStr string
+	}{
+		{"0", 0, ""},
+		{"512", 512, ""},
+		{"512B", 512, ""},
+		{"10KB", 10_000, ""},
+		{"10kib", 10 << 10, ""},
+		{"1.5GiB", 3 << 29, ""},
+		{"2T", 2 << 40, ""},
+		{" 3 MB ", 3_000_000, ""},
+		{"10XB", 0, `invalid size "10XB": unknown unit "XB"`},
+		{"GiB", 0, `invalid size "GiB": must be a non-negative number with an optional unit`},
+		{"1.2.3K", 0, `invalid size "1.2.3K": must be a non-negative number with an optional unit`},
+		{"100000000PB", 0, `invalid size "100000000PB": too large`},
+		{"9223372036854774784", math.MaxInt64 - 1023, ""}, // largest float64 below 2^63.
+		{"8192P", 0, `invalid size "8192P": too large`},
+	}
+
+	for _, tc := range testCases {
+		size, err := ParseSize(tc.s)
+		if tc.wantErrStr != "" {
+			failIfOk(t, err)
+			mustEqual(t, err.Error(), tc.wantErrStr)
+			continue
+		}
+		failIfErr(t, err)
+		mustEqual(t, size, tc.want)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	testCases := []struct {
+		s          string
+		want       time.Duration
+		wantErrStr string
+	}{
+		{"90m", 90 * time.Minute, ""},
+		{"1h30m", 90 * time.Minute, ""},
+		{"2d", 48 * time.Hour, ""},
+		{"1w2d3h", (7*24 + 2*24 + 3) * time.Hour, ""},
+		{"1.5d", 36 * time.Hour, ""},
+		{"-1d", -24 * time.Hour, ""},
+		{"", 0, `invalid duration ""`},
+		{"d", 0, `invalid duration "d"`},
+		{"10y", 0, `invalid duration "10y"`},
+		{"15000w", 15000 * 7 * 24 * time.Hour, ""},
+		{"300000w", 0, `invalid duration "300000w": too large`},
+		{"106751d1w", 0, `invalid duration "106751d1w": too large`},
+		{"2562047h1d", 0, `invalid duration "2562047h1d": too large`},
+	}
+
+	for _, tc := range testCases {
+		d, err := ParseDuration(tc.s)
+		if tc.wantErrStr != "" {
+			failIfOk(t, err)
+			mustEqual(t, err.Error(), tc.wantErrStr)
+			continue
+		}
+		failIfErr(t, err)
+		mustEqual(t, d, tc.want)
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	testCases := []struct {
+		s          string
+		want       float64
+		wantErrStr string
+	}{
+		{"50%", 0.5, ""},
+		{"12.5", 0.125, ""},
+		{"150 %", 1.5, ""},
+		{"-5%", 0, `invalid percent "-5%": must be a non-negative number with an optional %`},
+		{"half", 0, `invalid percent "half": must be a non-negative number with an optional %`},
+		{"NaN%", 0, `invalid percent "NaN%": must be a non-negative number with an optional %`},
+		{"inf", 0, `invalid percent "inf": must be a non-negative number with an optional %`},
+	}
+
+	for _, tc := range testCases {
+		p, err := ParsePercent(tc.s)
+		if tc.wantErrStr != "" {
+			failIfOk(t, err)
+			mustEqual(t, err.Error(), tc.wantErrStr)
+			continue
+		}
+		failIfErr(t, err)
+		mustEqual(t, p, tc.want)
+	}
+}
+
+func TestUnitFlagValues(t *testing.T) {
+	var (
+		size  int64
+		ttl   time.Duration
+		ratio float64
+	)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var((*SizeValue)(&size), "limit", "max size")
+	fs.Var((*DurationValue)(&ttl), "ttl", "time to live")
+	fs.Var((*PercentValue)(&ratio), "ratio", "sampling ratio")
+
+	failIfErr(t, fs.Parse([]string{"-limit=1KiB", "-ttl=1w", "-ratio=25%"}))
+	mustEqual(t, size, int64(1024))
+	mustEqual(t, ttl, 7*24*time.Hour)
+	mustEqual(t, ratio, 0.25)
+	mustEqual(t, fs.Lookup("ratio").Value.String(), "25%")
+
+	err := fs.Parse([]string{"-limit=lots"})
+	failIfOk(t, err)
+	mustEqual(t, err.Error(), `invalid value "lots" for flag -limit: invalid size "lots": must be a non-negative number with an optional unit`)
+}
@@ -0,0 +1,63 @@
+package acmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// WarningsMode defines when warnings from Warn are printed.
+type WarningsMode int
+
+const (
+	// WarningsDeferred prints warnings once (without duplicates) after the command completes.
+	WarningsDeferred WarningsMode = iota
+
+	// WarningsImmediate prints warnings as soon as they are reported.
+	WarningsImmediate
+)
+
+// Warn reports a non-fatal diagnostic, ex: a deprecation or a configuration issue.
+// Warnings are printed to Config.ErrOutput according to Config.WarningsMode.
+// If ctx is not the one passed to the command by the Runner, msg is printed to os.Stderr.
+func Warn(ctx context.Context, msg string) {
+	inv := invocationFrom(ctx)
+	if inv == nil {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", msg)
+		return
+	}
+
+	r := inv.runner
+	r.emitEvent(Event{Type: "warning", Command: strings.Join(inv.path, " "), Message: msg})
+
+	if r.cfg.WarningsMode == WarningsImmediate {
+		r.printWarning(msg)
+		return
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	for _, w := range inv.warnings {
+		if w == msg {
+			return
+		}
+	}
+	inv.warnings = append(inv.warnings, msg)
+}
+
+// printWarnings collected during the invocation.
+func (inv *invocation) printWarnings() {
+	inv.mu.Lock()
+	warnings := inv.warnings
+	inv.warnings = nil
+	inv.mu.Unlock()
+
+	for _, msg := range warnings {
+		inv.runner.printWarning(msg)
+	}
+}
+
+func (r *Runner) printWarning(msg string) {
+	r.printErr("%s: warning: %s\n", r.cfg.AppName, msg)
+}
@@ -0,0 +1,77 @@
+package acmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWarn(t *testing.T) {
+	testCases := []struct {
+		mode WarningsMode
+		want string
+	}{
+		{
+			mode: WarningsDeferred,
+			want: "run;myapp: warning: flag -x is deprecated\nmyapp: warning: no config found\n",
+		},
+		{
+			mode: WarningsImmediate,
+			want: "myapp: warning: flag -x is deprecated\nmyapp: warning: no config found\nmyapp: warning: flag -x is deprecated\nrun;",
+		},
+	}
+
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		cmds := []Command{{
+			Name: "foo",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				Warn(ctx, "flag -x is deprecated")
+				Warn(ctx, "no config found")
+				Warn(ctx, "flag -x is deprecated")
+				buf.WriteString("run;")
+				return nil
+			},
+		}}
+
+		r := RunnerOf(cmds, Config{
+			AppName:      "myapp",
+			Args:         []string{"./myapp", "foo"},
+			Output:       buf,
+			ErrOutput:    buf,
+			WarningsMode: tc.mode,
+		})
+		failIfErr(t, r.Run())
+		mustEqual(t, buf.String(), tc.want)
+	}
+}
+
+func TestWarn_concurrent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmds := []Command{{
+		Name: "sync",
+		ExecFunc: func(ctx context.Context, args []string) error {
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					Warn(ctx, "slow mirror")
+				}()
+			}
+			wg.Wait()
+			return nil
+		},
+	}}
+	r := RunnerOf(cmds, Config{
+		AppName:      "myapp",
+		Args:         []string{"./myapp", "sync"},
+		Output:       buf,
+		ErrOutput:    buf,
+		WarningsMode: WarningsImmediate,
+	})
+	failIfErr(t, r.Run())
+	mustEqual(t, buf.String(), strings.Repeat("myapp: warning: slow mirror\n", 10))
+}
@@ -0,0 +1,47 @@
+package acmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func (r *Runner) whichCmd() Command {
+	return Command{
+		Name:        "which",
+		Description: "shows what the command path resolves to",
+		ExecFunc: builtinFunc(func(ctx context.Context, args []string) error {
+			if len(args) == 0 {
+				return errors.New("usage: which <command...>")
+			}
+			path, cmd, err := lookupCommand(&r.cfg, r.cmds, args)
+			if err != nil {
+				return err
+			}
+			if cmd == nil {
+				return fmt.Errorf("%q not found", strings.Join(args, " "))
+			}
+			fmt.Fprintf(r.cfg.Output, "%s: %s\n", strings.Join(args, " "), r.resolution(args, path))
+			return nil
+		}),
+	}
+}
+
+// resolution describes what args resolve to, ex: `alias of "time next"`.
+func (r *Runner) resolution(args, path []string) string {
+	var res string
+	switch {
+	case strings.Join(args, " ") != strings.Join(path, " "):
+		res = fmt.Sprintf("alias of %q", strings.Join(path, " "))
+	case isReserved(&r.cfg, path[0]):
+		res = "built-in command"
+	default:
+		res = "command"
+	}
+
+	if provider, ok := r.providedBy[path[0]]; ok {
+		res += fmt.Sprintf(" from provider %d", provider)
+	}
+	return res
+}